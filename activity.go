@@ -0,0 +1,76 @@
+package gpx
+
+import (
+	"math"
+	"sort"
+)
+
+// An ActivitySpeed gives the speeds in meters per second up to which
+// GuessActivityFromSpeed classifies a document as Activity.
+type ActivitySpeed struct {
+	Activity   string
+	MaxAverage float64 // Highest average moving speed
+	MaxPeak    float64 // Highest peak moving speed
+}
+
+// ActivitySpeeds is the table GuessActivityFromSpeed classifies documents
+// with, ordered by increasing speed. Applications may replace it.
+//
+//	activity  average      peak
+//	walking   ≤ 7.2 km/h   ≤ 12.6 km/h
+//	running   ≤ 18 km/h    ≤ 28.8 km/h
+//	cycling   ≤ 45 km/h    ≤ 90 km/h
+//	driving   ≤ 250 km/h   ≤ 360 km/h
+var ActivitySpeeds = []ActivitySpeed{
+	{Activity: "walking", MaxAverage: 2, MaxPeak: 3.5},
+	{Activity: "running", MaxAverage: 5, MaxPeak: 8},
+	{Activity: "cycling", MaxAverage: 12.5, MaxPeak: 25},
+	{Activity: "driving", MaxAverage: 70, MaxPeak: 100},
+}
+
+// UnknownActivity is returned by GuessActivityFromSpeed when the speed
+// profile matches no activity.
+const UnknownActivity = "unknown"
+
+// GuessActivityFromSpeed guesses the kind of activity the document records
+// from its speed profile, for documents whose tracks don't state their type.
+// It takes the average speed while moving at DefaultMovingSpeed and the
+// 95th percentile of the speeds between moving points as the peak speed,
+// which ignores isolated GPS spikes. The first entry of ActivitySpeeds whose
+// maximum average speed isn't exceeded is returned if the peak speed fits
+// that entry too. Otherwise, or when the document has no timed movement,
+// UnknownActivity is returned.
+func (d Document) GuessActivityFromSpeed() string {
+	var speeds []float64
+	var distance, seconds float64
+	for _, t := range d.Tracks {
+		for _, s := range t.Segments {
+			for i := 1; i < len(s.Points); i++ {
+				prev, p := s.Points[i-1], s.Points[i]
+				speed, ok := edgeSpeed(prev, p)
+				if !ok || speed < DefaultMovingSpeed {
+					continue
+				}
+				speeds = append(speeds, speed)
+				distance += prev.DistanceTo(p)
+				seconds += p.Time.Sub(prev.Time).Seconds()
+			}
+		}
+	}
+	if len(speeds) == 0 {
+		return UnknownActivity
+	}
+
+	sort.Float64s(speeds)
+	peak := speeds[int(math.Ceil(0.95*float64(len(speeds))))-1]
+	average := distance / seconds
+	for _, as := range ActivitySpeeds {
+		if average <= as.MaxAverage {
+			if peak <= as.MaxPeak {
+				return as.Activity
+			}
+			break
+		}
+	}
+	return UnknownActivity
+}
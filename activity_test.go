@@ -0,0 +1,61 @@
+package gpx
+
+import (
+	"testing"
+	"time"
+)
+
+// steadyDocument returns a document moving east along the equator at speed
+// meters per second for n seconds.
+func steadyDocument(speed float64, n int) Document {
+	t0 := time.Date(2015, 12, 13, 18, 0, 0, 0, time.UTC)
+	degreesPerSecond := speed / haversine(0, 0, 0, 1)
+	var points []Point
+	for i := 0; i <= n; i++ {
+		points = append(points, Point{
+			Longitude: float64(i) * degreesPerSecond,
+			Time:      t0.Add(time.Duration(i) * time.Second),
+		})
+	}
+	return FromPoints(points)
+}
+
+func TestGuessActivityFromSpeed(t *testing.T) {
+	testCases := []struct {
+		speed    float64
+		activity string
+	}{
+		{1.4, "walking"},
+		{3, "running"},
+		{8, "cycling"},
+		{30, "driving"},
+		{300, UnknownActivity},
+	}
+	for _, tc := range testCases {
+		if activity := steadyDocument(tc.speed, 60).GuessActivityFromSpeed(); activity != tc.activity {
+			t.Errorf("got %q for %v m/s; expected %q", activity, tc.speed, tc.activity)
+		}
+	}
+
+	if activity := (Document{}).GuessActivityFromSpeed(); activity != UnknownActivity {
+		t.Errorf("got %q for an empty document; expected %q", activity, UnknownActivity)
+	}
+}
+
+func TestGuessActivityFromSpeedAmbiguous(t *testing.T) {
+	// A walking pace with long stretches far too fast for walking.
+	doc := steadyDocument(1, 60)
+	fast := steadyDocument(10, 5)
+	points := doc.Tracks[0].Segments[0].Points
+	last := points[len(points)-1]
+	for _, p := range fast.AllPoints()[1:] {
+		p.Longitude += last.Longitude
+		p.Time = last.Time.Add(p.Time.Sub(fast.Start()))
+		points = append(points, p)
+	}
+	doc = FromPoints(points)
+
+	if activity := doc.GuessActivityFromSpeed(); activity != UnknownActivity {
+		t.Errorf("got %q; expected %q", activity, UnknownActivity)
+	}
+}
@@ -0,0 +1,51 @@
+package gpx
+
+import (
+	"math"
+	"time"
+)
+
+// CompactPoint is a memory-dense representation of a Point for holding
+// millions of points. Latitude and longitude are stored as int32 in units
+// of 1e-7 degrees (the E7 format), which limits their precision to about
+// 1.1 cm. The elevation is stored as float32 (NaN if missing) and the time
+// with millisecond precision. Extensions, HDOP and the number of
+// satellites aren't retained.
+type CompactPoint struct {
+	LatitudeE7  int32
+	LongitudeE7 int32
+	Elevation   float32
+	Time        int64 // Milliseconds since the Unix epoch, 0 if missing
+}
+
+// Compact returns p as a CompactPoint.
+func (p Point) Compact() CompactPoint {
+	c := CompactPoint{
+		LatitudeE7:  int32(math.Round(p.Latitude * 1e7)),
+		LongitudeE7: int32(math.Round(p.Longitude * 1e7)),
+		Elevation:   float32(math.NaN()),
+	}
+	if p.hasElevation() {
+		c.Elevation = float32(p.Elevation)
+	}
+	if !p.Time.IsZero() {
+		c.Time = p.Time.UnixNano() / int64(time.Millisecond)
+	}
+	return c
+}
+
+// Point returns c as a Point.
+func (c CompactPoint) Point() Point {
+	p := Point{
+		Latitude:  float64(c.LatitudeE7) / 1e7,
+		Longitude: float64(c.LongitudeE7) / 1e7,
+	}
+	if !math.IsNaN(float64(c.Elevation)) {
+		p.Elevation = float64(c.Elevation)
+		p.HasElevation = true
+	}
+	if c.Time != 0 {
+		p.Time = time.Unix(0, c.Time*int64(time.Millisecond)).UTC()
+	}
+	return p
+}
@@ -0,0 +1,41 @@
+package gpx
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCompactPoint(t *testing.T) {
+	p := Point{
+		Latitude:     49.3973693847656250,
+		Longitude:    -11.1259574890136719,
+		Elevation:    346.5,
+		HasElevation: true,
+		Time:         time.Date(2015, 12, 13, 18, 35, 18, 250000000, time.UTC),
+	}
+
+	c := p.Compact()
+	if expected := int32(493973694); c.LatitudeE7 != expected {
+		t.Errorf("got %d latitude; expected %d", c.LatitudeE7, expected)
+	}
+
+	p2 := c.Point()
+	if d := p.DistanceTo(p2); d > 0.02 {
+		t.Errorf("got %f m distance after round trip; expected at most 2 cm", d)
+	}
+	if p2.Elevation != p.Elevation || !p2.HasElevation {
+		t.Errorf("got %v elevation; expected %v", p2.Elevation, p.Elevation)
+	}
+	if !p2.Time.Equal(p.Time) {
+		t.Errorf("got %v time; expected %v", p2.Time, p.Time)
+	}
+
+	empty := Point{Latitude: 1, Longitude: 2}.Compact().Point()
+	if empty.HasElevation || !empty.Time.IsZero() {
+		t.Errorf("got %+v; expected no elevation and time", empty)
+	}
+	if math.Abs(empty.Latitude-1) > 1e-7 || math.Abs(empty.Longitude-2) > 1e-7 {
+		t.Errorf("got %v,%v; expected 1,2", empty.Latitude, empty.Longitude)
+	}
+}
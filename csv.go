@@ -0,0 +1,98 @@
+package gpx
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrCSVMissingColumn = errors.New("gpx: CSV header must have lat and lon columns")
+)
+
+// DecodeCSV decodes CSV rows into a document with a single track and
+// segment. The first row is a header naming the columns: lat (or
+// latitude), lon (or lng, longitude), ele (or elevation) and time, in any
+// order and case. The lat and lon columns are required, other columns are
+// ignored. Empty or missing elevations and times are left at zero. Times
+// must be formatted as RFC 3339.
+func DecodeCSV(r io.Reader) (Document, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return Document{}, err
+	}
+	lat, lon, ele, tm := -1, -1, -1, -1
+	for i, name := range header {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "lat", "latitude":
+			lat = i
+		case "lon", "lng", "longitude":
+			lon = i
+		case "ele", "elevation":
+			ele = i
+		case "time":
+			tm = i
+		}
+	}
+	if lat < 0 || lon < 0 {
+		return Document{}, ErrCSVMissingColumn
+	}
+
+	var points []Point
+	for line := 2; ; line++ {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Document{}, err
+		}
+
+		var p Point
+		if p.Latitude, err = parseCSVFloat(row, lat); err != nil {
+			return Document{}, fmt.Errorf("gpx: invalid CSV lat on line %d: %s", line, err)
+		}
+		if p.Longitude, err = parseCSVFloat(row, lon); err != nil {
+			return Document{}, fmt.Errorf("gpx: invalid CSV lon on line %d: %s", line, err)
+		}
+		if s := csvField(row, ele); s != "" {
+			if p.Elevation, err = strconv.ParseFloat(s, 64); err != nil {
+				return Document{}, fmt.Errorf("gpx: invalid CSV ele on line %d: %s", line, err)
+			}
+			p.HasElevation = true
+		}
+		if s := csvField(row, tm); s != "" {
+			if p.Time, err = time.Parse(time.RFC3339Nano, s); err != nil {
+				return Document{}, fmt.Errorf("gpx: invalid CSV time on line %d: %s", line, err)
+			}
+		}
+		points = append(points, p)
+	}
+
+	return FromPoints(points), nil
+}
+
+// csvField returns the trimmed field i of row, or "" if there is none.
+func csvField(row []string, i int) string {
+	if i < 0 || i >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[i])
+}
+
+// parseCSVFloat parses field i of row, treating an empty field as zero.
+func parseCSVFloat(row []string, i int) (float64, error) {
+	s := csvField(row, i)
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
@@ -0,0 +1,43 @@
+package gpx
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeCSV(t *testing.T) {
+	const data = `time,Latitude,lon,ele,hr
+2015-12-13T18:35:18Z,49.3973693847656250,11.1259574890136719,346.874267578125,126
+,49.3968467712402344,11.1254367828369141,,133
+`
+
+	doc, err := DecodeCSV(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	points := doc.AllPoints()
+	if l := len(points); l != 2 {
+		t.Fatalf("got %d point(s); expected 2", l)
+	}
+
+	expected := Point{
+		Latitude:  49.3973693847656250,
+		Longitude: 11.1259574890136719,
+		Elevation: 346.874267578125,
+		Time:      time.Date(2015, 12, 13, 18, 35, 18, 0, time.UTC),
+	}
+	if p := points[0]; p.Latitude != expected.Latitude || p.Longitude != expected.Longitude || p.Elevation != expected.Elevation || !p.Time.Equal(expected.Time) {
+		t.Errorf("got %+v point; expected %+v", p, expected)
+	}
+	if p := points[1]; p.Elevation != 0 || !p.Time.IsZero() {
+		t.Errorf("got %+v point; expected zero elevation and time", p)
+	}
+
+	if _, err := DecodeCSV(strings.NewReader("time,ele\n")); err != ErrCSVMissingColumn {
+		t.Errorf("expected ErrCSVMissingColumn; got %v", err)
+	}
+	if _, err := DecodeCSV(strings.NewReader("lat,lon\nnorth,11\n")); err == nil {
+		t.Error("expected an error for an invalid latitude")
+	}
+}
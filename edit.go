@@ -0,0 +1,550 @@
+package gpx
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var (
+	ErrNotChronological = errors.New("gpx: segment points are not in chronological order")
+)
+
+// Insert inserts p into the segment at the position that keeps the
+// segment's timestamped points in chronological order. Points without a
+// timestamp are appended at the end. An error is returned if the segment's
+// existing timestamped points are not in chronological order, as there is
+// no correct position for p in that case.
+func (seg *Segment) Insert(p Point) error {
+	if p.Time.IsZero() {
+		seg.Points = append(seg.Points, p)
+		return nil
+	}
+
+	pos := -1
+	afterLast := 0
+	var prev Point
+	for i, q := range seg.Points {
+		if q.Time.IsZero() {
+			continue
+		}
+		if !prev.Time.IsZero() && q.Time.Before(prev.Time) {
+			return ErrNotChronological
+		}
+		if pos < 0 && q.Time.After(p.Time) {
+			pos = i
+		}
+		prev = q
+		afterLast = i + 1
+	}
+	if pos < 0 {
+		pos = afterLast
+	}
+
+	seg.Points = append(seg.Points, Point{})
+	copy(seg.Points[pos+1:], seg.Points[pos:])
+	seg.Points[pos] = p
+	return nil
+}
+
+// LooksSwapped reports whether the document's track points look like they
+// have their latitude and longitude swapped. This is the case when the
+// majority of points has a latitude outside [-90, 90] while the longitude
+// lies within it, or when the metadata bounds are set and contain the
+// majority of points only after swapping. Swaps of points within
+// [-90, 90] in both coordinates can't be detected without bounds, so a
+// false result doesn't guarantee the coordinates are correct.
+func (d Document) LooksSwapped() bool {
+	var n, invalid, inBounds, swappedInBounds int
+	b := d.Metadata.Bounds
+	for _, p := range d.AllPoints() {
+		n++
+		if math.Abs(p.Latitude) > 90 && math.Abs(p.Longitude) <= 90 {
+			invalid++
+		}
+		if b.contains(p.Latitude, p.Longitude) {
+			inBounds++
+		}
+		if b.contains(p.Longitude, p.Latitude) {
+			swappedInBounds++
+		}
+	}
+	if n == 0 {
+		return false
+	}
+	if invalid*2 > n {
+		return true
+	}
+	return b != (Bounds{}) && swappedInBounds*2 > n && inBounds*2 <= n
+}
+
+// SwapLatLon swaps the latitude and longitude of every track point. The
+// metadata bounds are left untouched.
+func (d *Document) SwapLatLon() {
+	for _, t := range d.Tracks {
+		for _, s := range t.Segments {
+			for i := range s.Points {
+				p := &s.Points[i]
+				p.Latitude, p.Longitude = p.Longitude, p.Latitude
+			}
+		}
+	}
+}
+
+// contains reports whether lat/lon lies within the bounds.
+func (b Bounds) contains(lat, lon float64) bool {
+	return lat >= b.MinLatitude && lat <= b.MaxLatitude &&
+		lon >= b.MinLongitude && lon <= b.MaxLongitude
+}
+
+// FilterByAccuracy returns a new segment holding only the points whose
+// horizontal dilution of precision is at most maxHDOP and whose fix used at
+// least minSats satellites. Points not recording HDOP or the number of
+// satellites are kept, as their accuracy is unknown rather than bad.
+func (s Segment) FilterByAccuracy(maxHDOP float64, minSats uint) Segment {
+	var filtered Segment
+	for _, p := range s.Points {
+		if p.HDOP != 0 && p.HDOP > maxHDOP {
+			continue
+		}
+		if p.Satellites != 0 && p.Satellites < minSats {
+			continue
+		}
+		filtered.Points = append(filtered.Points, p)
+	}
+	return filtered
+}
+
+// NullIslandRadius is the distance in meters from 0,0 within which
+// RemoveNullIsland considers a point a GPS glitch.
+const NullIslandRadius = 100.0
+
+// NullIslandNeighbourDistance is the distance in meters within which a
+// point near 0,0 must have a genuine neighbour for RemoveNullIsland to keep
+// it.
+const NullIslandNeighbourDistance = 10000.0
+
+// RemoveNullIsland returns a new segment without the points GPS receivers
+// drop at 0,0 when they lose their fix. A point within NullIslandRadius of
+// 0,0 is removed unless the nearest point before or after it that is not
+// itself near 0,0 lies within NullIslandNeighbourDistance, which is the case
+// when the track genuinely passes through the Gulf of Guinea. Segments made
+// up only of points near 0,0 are returned unchanged.
+func (s Segment) RemoveNullIsland() Segment {
+	null := make([]bool, len(s.Points))
+	genuine := false
+	for i, p := range s.Points {
+		null[i] = haversine(0, 0, p.Latitude, p.Longitude) <= NullIslandRadius
+		genuine = genuine || !null[i]
+	}
+	if !genuine {
+		return Segment{Points: append([]Point(nil), s.Points...)}
+	}
+
+	near := func(p Point, j, step int) bool {
+		for ; j >= 0 && j < len(s.Points); j += step {
+			if !null[j] {
+				return p.DistanceTo(s.Points[j]) <= NullIslandNeighbourDistance
+			}
+		}
+		return false
+	}
+
+	var cleaned Segment
+	for i, p := range s.Points {
+		if null[i] && !near(p, i-1, -1) && !near(p, i+1, 1) {
+			continue
+		}
+		cleaned.Points = append(cleaned.Points, p)
+	}
+	return cleaned
+}
+
+// MergeSegments joins consecutive segments of the track when the time
+// between the last point of one and the first point of the next is less
+// than maxGap. Segments whose boundary points lack a timestamp or that
+// overlap in time are kept apart.
+func (t *Track) MergeSegments(maxGap time.Duration) {
+	if len(t.Segments) < 2 {
+		return
+	}
+	// Capping the point slices makes the first append to each of them
+	// reallocate, so merging never writes into an array shared with other
+	// slices.
+	merged := []Segment{capped(t.Segments[0])}
+	for _, s := range t.Segments[1:] {
+		last := &merged[len(merged)-1]
+		end, start := last.End(), s.Start()
+		if !end.IsZero() && !start.IsZero() {
+			if gap := start.Sub(end); gap >= 0 && gap < maxGap {
+				last.Points = append(last.Points, s.Points...)
+				continue
+			}
+		}
+		merged = append(merged, capped(s))
+	}
+	t.Segments = merged
+}
+
+// capped returns s with the capacity of its points limited to their length.
+func capped(s Segment) Segment {
+	return Segment{Points: s.Points[:len(s.Points):len(s.Points)]}
+}
+
+// SplitByTimeGap splits the track where the time between consecutive
+// points exceeds gap, e.g. to break a multi-day trip into one track per
+// day. Every returned track keeps the name, type and extensions of t, and
+// the segment boundaries of t within it. Points without a timestamp never
+// start a new track; the time gap is measured from the last timestamped
+// point instead.
+func (t Track) SplitByTimeGap(gap time.Duration) []Track {
+	var last time.Time
+	return t.split(func(prev, p Point) bool {
+		if p.Time.IsZero() {
+			return false
+		}
+		split := !last.IsZero() && p.Time.Sub(last) > gap
+		last = p.Time
+		return split
+	})
+}
+
+// SplitByDistanceGap splits the track where the distance in meters between
+// consecutive points exceeds meters, e.g. where the receiver lost its fix
+// while moving. The returned tracks are as described for SplitByTimeGap.
+func (t Track) SplitByDistanceGap(meters float64) []Track {
+	return t.split(func(prev, p Point) bool {
+		return prev.DistanceTo(p) > meters
+	})
+}
+
+// split starts a new track before every point for which gap returns true
+// given the point before it. gap is called for every point but the first.
+func (t Track) split(gap func(prev, p Point) bool) []Track {
+	var tracks []Track
+	piece := Track{Name: t.Name, Type: t.Type, Extensions: t.Extensions}
+	var prev *Point
+	for _, s := range t.Segments {
+		var seg Segment
+		for i, p := range s.Points {
+			if prev != nil && gap(*prev, p) {
+				if len(seg.Points) > 0 {
+					piece.Segments = append(piece.Segments, seg)
+				}
+				tracks = append(tracks, piece)
+				piece = Track{Name: t.Name, Type: t.Type, Extensions: t.Extensions}
+				seg = Segment{}
+			}
+			seg.Points = append(seg.Points, p)
+			prev = &s.Points[i]
+		}
+		if len(seg.Points) > 0 {
+			piece.Segments = append(piece.Segments, seg)
+		}
+	}
+	return append(tracks, piece)
+}
+
+// Trim configures how many points to drop from the start and end of each
+// segment, e.g. to remove the erratic positions recorded while the GPS fix
+// settles. The zero value trims nothing; negative values are treated as
+// zero.
+type Trim struct {
+	Points   int           // Number of points to drop at each end
+	Duration time.Duration // Time to drop at each end, for timestamped points
+}
+
+// Trimmed returns a copy of the document with the ends of every segment
+// trimmed as configured by trim. Both criteria apply when both are set.
+// Segments that would lose all their points are left empty.
+func (d Document) Trimmed(trim Trim) Document {
+	trimmed := d
+	trimmed.Tracks = make([]Track, len(d.Tracks))
+	for i, t := range d.Tracks {
+		trimmed.Tracks[i] = t
+		trimmed.Tracks[i].Segments = make([]Segment, len(t.Segments))
+		for j, s := range t.Segments {
+			s = s.trimmed(trim)
+			s.Points = append([]Point(nil), s.Points...)
+			trimmed.Tracks[i].Segments[j] = s
+		}
+	}
+	return trimmed
+}
+
+// trimmed returns the segment with its ends trimmed. Its points share the
+// array of s, but are capped so appending to them doesn't overwrite s.
+func (s Segment) trimmed(trim Trim) Segment {
+	n := trim.Points
+	if n < 0 {
+		n = 0
+	}
+	from, to := n, len(s.Points)-n
+	if trim.Duration > 0 {
+		if start := s.Start(); !start.IsZero() {
+			for from < to && s.Points[from].Time.Sub(start) < trim.Duration {
+				from++
+			}
+		}
+		if end := s.End(); !end.IsZero() {
+			for to > from && end.Sub(s.Points[to-1].Time) < trim.Duration {
+				to--
+			}
+		}
+	}
+	if from >= to {
+		return Segment{}
+	}
+	return Segment{Points: s.Points[from:to:to]}
+}
+
+// SimilarityTolerance is the distance in meters within which a point is
+// considered to lie on another track when computing track similarity.
+const SimilarityTolerance = 20.0
+
+// Similarity returns how similar the geometry of the track is to that of
+// t2 as a value between 0 (disjoint) and 1 (identical). It is the fraction
+// of the points of both tracks that lie within SimilarityTolerance of a
+// point of the other track. Comparing every pair of points makes it
+// quadratic in the number of points.
+func (t Track) Similarity(t2 Track) float64 {
+	var points1, points2 []Point
+	for _, s := range t.Segments {
+		points1 = append(points1, s.Points...)
+	}
+	for _, s := range t2.Segments {
+		points2 = append(points2, s.Points...)
+	}
+	if len(points1) == 0 || len(points2) == 0 {
+		return 0
+	}
+	near := nearPoints(points1, points2) + nearPoints(points2, points1)
+	return float64(near) / float64(len(points1)+len(points2))
+}
+
+// nearPoints returns how many points of points1 lie within
+// SimilarityTolerance of a point of points2.
+func nearPoints(points1, points2 []Point) int {
+	var n int
+	for _, p := range points1 {
+		for _, q := range points2 {
+			if p.DistanceTo(q) <= SimilarityTolerance {
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
+
+// DedupTracks removes tracks whose Similarity to an earlier track exceeds
+// similarity, keeping the first of each group of duplicates.
+func (d *Document) DedupTracks(similarity float64) {
+	var kept []Track
+	for _, t := range d.Tracks {
+		duplicate := false
+		for _, k := range kept {
+			if t.Similarity(k) > similarity {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			kept = append(kept, t)
+		}
+	}
+	d.Tracks = kept
+}
+
+// OverlappingTracks returns the index pairs of tracks whose time ranges
+// overlap, such as recordings of the same activity by two devices. Merging
+// such tracks naively counts their distance twice. Tracks merely touching at
+// their boundaries and tracks without timestamps don't overlap.
+func (d Document) OverlappingTracks() [][2]int {
+	type timeRange struct {
+		start, end time.Time
+		ok         bool
+	}
+	ranges := make([]timeRange, len(d.Tracks))
+	for i, t := range d.Tracks {
+		r := &ranges[i]
+		r.start, r.end, r.ok = t.TimeRange()
+	}
+
+	var pairs [][2]int
+	for i, a := range ranges {
+		for j := i + 1; j < len(ranges); j++ {
+			b := ranges[j]
+			if a.ok && b.ok && a.start.Before(b.end) && b.start.Before(a.end) {
+				pairs = append(pairs, [2]int{i, j})
+			}
+		}
+	}
+	return pairs
+}
+
+// RecomputeBounds sets the metadata bounds to the bounding box of the
+// document's track points, replacing stale or missing bounds.
+func (d *Document) RecomputeBounds() {
+	d.Metadata.Bounds = d.BoundingBox()
+}
+
+// Append appends the tracks, routes and waypoints of other to the document,
+// after its own. Tracks are appended as they are: they are not reordered,
+// so the result is only chronological when other was recorded after the
+// document, and partially overlapping tracks stay overlapping. The metadata
+// time becomes the earliest of both, and when either document declares
+// metadata bounds they are recomputed from the combined track points,
+// waypoints and route points, or joined if there are no points.
+func (d *Document) Append(other Document) {
+	d.Tracks = append(d.Tracks, other.Tracks...)
+	d.Routes = append(d.Routes, other.Routes...)
+	d.Waypoints = append(d.Waypoints, other.Waypoints...)
+
+	if d.Metadata.Time.IsZero() || (!other.Metadata.Time.IsZero() && other.Metadata.Time.Before(d.Metadata.Time)) {
+		d.Metadata.Time = other.Metadata.Time
+	}
+	if b1, b2 := d.Metadata.Bounds, other.Metadata.Bounds; b1 != (Bounds{}) || b2 != (Bounds{}) {
+		if b, ok := d.ComputeBounds(); ok {
+			d.Metadata.Bounds = b
+		} else if b1 == (Bounds{}) {
+			d.Metadata.Bounds = b2
+		} else if b2 != (Bounds{}) {
+			d.Metadata.Bounds = b1.union(b2)
+		}
+	}
+}
+
+// Merge returns a new document holding the tracks, routes and waypoints of
+// docs in order, e.g. to stitch an activity recorded in several files. The
+// version and metadata of the first document are kept, apart from the
+// metadata time and bounds, which are combined as described for Append.
+func Merge(docs ...Document) Document {
+	var m Document
+	if len(docs) == 0 {
+		return m
+	}
+	m.Version = docs[0].Version
+	m.OriginalVersion = docs[0].OriginalVersion
+	m.Metadata = docs[0].Metadata
+	for _, d := range docs {
+		m.Append(d)
+	}
+	return m
+}
+
+// ExtractSegment returns a new document holding only segment segIdx of
+// track trackIdx. The track's name and type and the document's metadata are
+// kept, except for the metadata bounds and time, which are recomputed from
+// the segment.
+func (d Document) ExtractSegment(trackIdx, segIdx int) (Document, error) {
+	if trackIdx < 0 || trackIdx >= len(d.Tracks) {
+		return Document{}, fmt.Errorf("gpx: track index %d out of range", trackIdx)
+	}
+	t := d.Tracks[trackIdx]
+	if segIdx < 0 || segIdx >= len(t.Segments) {
+		return Document{}, fmt.Errorf("gpx: segment index %d out of range", segIdx)
+	}
+	t.Segments = []Segment{t.Segments[segIdx]}
+
+	extract := t.Document()
+	metadata := d.Metadata
+	metadata.Bounds = extract.Metadata.Bounds
+	metadata.Time = extract.Metadata.Time
+	extract.Metadata = metadata
+	return extract, nil
+}
+
+// RebaseTime shifts every timestamp of the document, including the
+// metadata time, by the same offset so that the first timestamped track
+// point is at newStart. Intervals between timestamps are preserved. The
+// metadata time is used as the reference when no track point has a
+// timestamp; a document without any timestamps is left unchanged. Missing
+// timestamps stay missing.
+func (d *Document) RebaseTime(newStart time.Time) {
+	ref := d.Metadata.Time
+	for _, p := range d.AllPoints() {
+		if !p.Time.IsZero() {
+			ref = p.Time
+			break
+		}
+	}
+	if ref.IsZero() {
+		return
+	}
+
+	offset := newStart.Sub(ref)
+	shift := func(t *time.Time) {
+		if !t.IsZero() {
+			*t = t.Add(offset)
+		}
+	}
+	shift(&d.Metadata.Time)
+	for i := range d.Waypoints {
+		shift(&d.Waypoints[i].Time)
+	}
+	for i := range d.Routes {
+		for j := range d.Routes[i].Points {
+			shift(&d.Routes[i].Points[j].Time)
+		}
+	}
+	for i := range d.Tracks {
+		for j := range d.Tracks[i].Segments {
+			for k := range d.Tracks[i].Segments[j].Points {
+				shift(&d.Tracks[i].Segments[j].Points[k].Time)
+			}
+		}
+	}
+}
+
+// Simplify returns a copy of the segment simplified with the
+// Ramer–Douglas–Peucker algorithm: points lying within epsilon meters of
+// the simplified line are dropped. The first and last point are always
+// kept, and the kept points are left untouched. Segments with fewer than
+// three points are returned as is.
+func (s Segment) Simplify(epsilon float64) Segment {
+	n := len(s.Points)
+	if n < 3 {
+		return Segment{Points: append([]Point(nil), s.Points...)}
+	}
+
+	keep := make([]bool, n)
+	keep[0], keep[n-1] = true, true
+	stack := [][2]int{{0, n - 1}}
+	for len(stack) > 0 {
+		first, last := stack[len(stack)-1][0], stack[len(stack)-1][1]
+		stack = stack[:len(stack)-1]
+
+		a, b := s.Points[first], s.Points[last]
+		index, max := -1, epsilon
+		for i := first + 1; i < last; i++ {
+			p := s.Points[i]
+			if d := segmentDistance(a.Latitude, a.Longitude, b.Latitude, b.Longitude, p.Latitude, p.Longitude); d > max {
+				index, max = i, d
+			}
+		}
+		if index >= 0 {
+			keep[index] = true
+			stack = append(stack, [2]int{first, index}, [2]int{index, last})
+		}
+	}
+
+	var simplified Segment
+	for i, p := range s.Points {
+		if keep[i] {
+			simplified.Points = append(simplified.Points, p)
+		}
+	}
+	return simplified
+}
+
+// Simplify simplifies every segment of the document with
+// Segment.Simplify.
+func (d *Document) Simplify(epsilon float64) {
+	for i := range d.Tracks {
+		for j, s := range d.Tracks[i].Segments {
+			d.Tracks[i].Segments[j] = s.Simplify(epsilon)
+		}
+	}
+}
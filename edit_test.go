@@ -0,0 +1,490 @@
+package gpx
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSegmentInsert(t *testing.T) {
+	t0 := time.Date(2015, 12, 13, 18, 0, 0, 0, time.UTC)
+	seg := Segment{Points: []Point{
+		{Latitude: 1, Time: t0},
+		{Latitude: 3, Time: t0.Add(2 * time.Second)},
+	}}
+
+	if err := seg.Insert(Point{Latitude: 2, Time: t0.Add(time.Second)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := seg.Insert(Point{Latitude: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if err := seg.Insert(Point{Latitude: 4, Time: t0.Add(3 * time.Second)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := seg.Insert(Point{Latitude: 0, Time: t0.Add(-time.Second)}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, p := range seg.Points {
+		if p.Latitude != float64(i) {
+			t.Errorf("got latitude %v at index %d; expected %d", p.Latitude, i, i)
+		}
+	}
+
+	seg.Points[0].Time = t0.Add(time.Hour)
+	if err := seg.Insert(Point{Time: t0}); err != ErrNotChronological {
+		t.Errorf("expected ErrNotChronological; got %v", err)
+	}
+}
+
+func TestLooksSwapped(t *testing.T) {
+	doc := FromPoints([]Point{
+		{Latitude: 151.20, Longitude: -33.86},
+		{Latitude: 151.21, Longitude: -33.87},
+	})
+	if !doc.LooksSwapped() {
+		t.Error("expected document with latitudes beyond 90 to look swapped")
+	}
+	doc.SwapLatLon()
+	if doc.LooksSwapped() {
+		t.Error("expected document not to look swapped after SwapLatLon")
+	}
+	if p := doc.Tracks[0].Segments[0].Points[0]; p.Latitude != -33.86 || p.Longitude != 151.20 {
+		t.Errorf("got %v,%v; expected -33.86,151.20", p.Latitude, p.Longitude)
+	}
+
+	doc = FromPoints([]Point{
+		{Latitude: 11.12, Longitude: 49.39},
+		{Latitude: 11.13, Longitude: 49.40},
+	})
+	doc.Metadata.Bounds = Bounds{MinLatitude: 49, MinLongitude: 11, MaxLatitude: 50, MaxLongitude: 12}
+	if !doc.LooksSwapped() {
+		t.Error("expected document outside its bounds to look swapped")
+	}
+}
+
+func TestSegmentFilterByAccuracy(t *testing.T) {
+	seg := Segment{Points: []Point{
+		{Latitude: 1, HDOP: 0.9, Satellites: 9},
+		{Latitude: 2, HDOP: 8.5, Satellites: 9},
+		{Latitude: 3, HDOP: 1.2, Satellites: 3},
+		{Latitude: 4},
+	}}
+
+	filtered := seg.FilterByAccuracy(2, 4)
+	if l := len(filtered.Points); l != 2 {
+		t.Fatalf("got %d point(s); expected 2", l)
+	}
+	if filtered.Points[0].Latitude != 1 || filtered.Points[1].Latitude != 4 {
+		t.Errorf("got %v and %v latitude; expected 1 and 4", filtered.Points[0].Latitude, filtered.Points[1].Latitude)
+	}
+	if l := len(seg.Points); l != 4 {
+		t.Errorf("original segment has %d point(s); expected 4", l)
+	}
+}
+
+func TestTrackMergeSegments(t *testing.T) {
+	t0 := time.Date(2015, 12, 13, 18, 0, 0, 0, time.UTC)
+	track := Track{Segments: []Segment{
+		{Points: []Point{{Time: t0}, {Time: t0.Add(time.Second)}}},
+		{Points: []Point{{Time: t0.Add(10 * time.Second)}}},
+		{Points: []Point{{Time: t0.Add(2 * time.Hour)}}},
+		{Points: []Point{{}}},
+	}}
+
+	track.MergeSegments(time.Minute)
+	if l := len(track.Segments); l != 3 {
+		t.Fatalf("got %d segment(s); expected 3", l)
+	}
+	if l := len(track.Segments[0].Points); l != 3 {
+		t.Errorf("got %d point(s) in the first segment; expected 3", l)
+	}
+}
+
+func TestTrackMergeSegmentsSharedPoints(t *testing.T) {
+	t0 := time.Date(2015, 12, 13, 18, 0, 0, 0, time.UTC)
+	points := []Point{
+		{Latitude: 1, Time: t0},
+		{Latitude: 2, Time: t0.Add(time.Second)},
+		{Latitude: 3, Time: t0.Add(2 * time.Second)},
+		{Latitude: 4, Time: t0.Add(3 * time.Second)},
+	}
+	track := Track{Segments: []Segment{
+		{Points: points[:2]},
+		{Points: points[3:]},
+	}}
+
+	track.MergeSegments(time.Minute)
+	if l := len(track.Segments[0].Points); l != 3 {
+		t.Fatalf("got %d point(s) in the merged segment; expected 3", l)
+	}
+	if points[2].Latitude != 3 {
+		t.Errorf("got %v latitude for a point outside the track; expected it to be untouched", points[2].Latitude)
+	}
+}
+
+func TestTrackMergeSegmentsMany(t *testing.T) {
+	t0 := time.Date(2015, 12, 13, 18, 0, 0, 0, time.UTC)
+	var track Track
+	for i := 0; i < 5000; i++ {
+		var s Segment
+		for j := 0; j < 20; j++ {
+			s.Points = append(s.Points, Point{Time: t0.Add(time.Duration(i*20+j) * time.Second)})
+		}
+		track.Segments = append(track.Segments, s)
+	}
+
+	track.MergeSegments(time.Minute)
+	if l := len(track.Segments); l != 1 {
+		t.Fatalf("got %d segment(s); expected 1", l)
+	}
+	if l := len(track.Segments[0].Points); l != 100000 {
+		t.Errorf("got %d point(s); expected 100000", l)
+	}
+}
+
+func TestDocumentTrimmed(t *testing.T) {
+	t0 := time.Date(2015, 12, 13, 18, 0, 0, 0, time.UTC)
+	var points []Point
+	for i := 0; i < 10; i++ {
+		points = append(points, Point{Latitude: float64(i), Time: t0.Add(time.Duration(i) * time.Second)})
+	}
+	doc := FromPoints(points)
+
+	trimmed := doc.Trimmed(Trim{Points: 2})
+	if got := trimmed.AllPoints(); len(got) != 6 || got[0].Latitude != 2 || got[5].Latitude != 7 {
+		t.Errorf("got %d point(s) from %v; expected 6 from 2", len(got), got[0].Latitude)
+	}
+
+	trimmed = doc.Trimmed(Trim{Duration: 3 * time.Second})
+	if got := trimmed.AllPoints(); len(got) != 4 || got[0].Latitude != 3 || got[3].Latitude != 6 {
+		t.Errorf("got %d point(s) from %v; expected 4 from 3", len(got), got[0].Latitude)
+	}
+
+	if got := doc.Trimmed(Trim{Points: 5}).AllPoints(); len(got) != 0 {
+		t.Errorf("got %d point(s); expected none", len(got))
+	}
+	if got := doc.Trimmed(Trim{Points: -1}).AllPoints(); len(got) != 10 {
+		t.Errorf("got %d point(s) for a negative trim; expected 10", len(got))
+	}
+
+	trimmed = doc.Trimmed(Trim{Points: 1})
+	trimmed.Tracks[0].Segments[0].Points[0].Latitude = 99
+	trimmed.Tracks[0].Segments[0].Points = append(trimmed.Tracks[0].Segments[0].Points, Point{Latitude: 99})
+	for i, p := range doc.AllPoints() {
+		if p.Latitude != float64(i) {
+			t.Errorf("got %v latitude for point %d of the original document; expected %d", p.Latitude, i, i)
+		}
+	}
+	if l := len(doc.AllPoints()); l != 10 {
+		t.Errorf("original document has %d point(s); expected 10", l)
+	}
+}
+
+func TestDedupTracks(t *testing.T) {
+	start := Point{Latitude: 49.3973693847656250, Longitude: 11.1259574890136719}
+	track := GenerateTrack(GenerateOptions{Points: 20, Spacing: 10, Start: start})
+	other := GenerateTrack(GenerateOptions{Points: 20, Spacing: 10, Bearing: 180, Start: start})
+
+	if s := track.Similarity(track); s != 1 {
+		t.Errorf("got %f similarity to itself; expected 1", s)
+	}
+	if s := track.Similarity(other); s >= 0.5 {
+		t.Errorf("got %f similarity to a different track; expected less than 0.5", s)
+	}
+
+	doc := Document{Tracks: []Track{track, other, track}}
+	doc.DedupTracks(0.9)
+	if l := len(doc.Tracks); l != 2 {
+		t.Errorf("got %d track(s); expected 2", l)
+	}
+}
+
+func TestRecomputeBounds(t *testing.T) {
+	doc := FromPoints([]Point{{Latitude: 49.1, Longitude: 11.1}, {Latitude: 49.3, Longitude: 11.2}})
+	doc.Metadata.Bounds = Bounds{MinLatitude: 1, MinLongitude: 2, MaxLatitude: 3, MaxLongitude: 4}
+
+	doc.RecomputeBounds()
+	if expected := (Bounds{MinLatitude: 49.1, MinLongitude: 11.1, MaxLatitude: 49.3, MaxLongitude: 11.2}); doc.Metadata.Bounds != expected {
+		t.Errorf("got %+v bounds; expected %+v", doc.Metadata.Bounds, expected)
+	}
+}
+
+func TestExtractSegment(t *testing.T) {
+	t0 := time.Date(2015, 12, 13, 18, 0, 0, 0, time.UTC)
+	doc := Document{
+		Version:  "1.1",
+		Metadata: Metadata{Name: "Run", Time: t0},
+		Tracks: []Track{{
+			Name: "Running",
+			Segments: []Segment{
+				{Points: []Point{{Latitude: 1, Longitude: 1, Time: t0}}},
+				{Points: []Point{{Latitude: 2, Longitude: 3, Time: t0.Add(time.Hour)}, {Latitude: 4, Longitude: 5}}},
+			},
+		}},
+	}
+
+	extract, err := doc.ExtractSegment(0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := len(extract.AllPoints()); l != 2 {
+		t.Errorf("got %d point(s); expected 2", l)
+	}
+	if extract.Metadata.Name != "Run" || extract.Tracks[0].Name != "Running" {
+		t.Errorf("got %q and %q names; expected Run and Running", extract.Metadata.Name, extract.Tracks[0].Name)
+	}
+	if expected := t0.Add(time.Hour); !extract.Metadata.Time.Equal(expected) {
+		t.Errorf("got %v metadata time; expected %v", extract.Metadata.Time, expected)
+	}
+	if expected := (Bounds{MinLatitude: 2, MinLongitude: 3, MaxLatitude: 4, MaxLongitude: 5}); extract.Metadata.Bounds != expected {
+		t.Errorf("got %+v bounds; expected %+v", extract.Metadata.Bounds, expected)
+	}
+	if l := len(doc.Tracks[0].Segments); l != 2 {
+		t.Errorf("original document has %d segment(s); expected 2", l)
+	}
+
+	for _, idx := range [][2]int{{1, 0}, {0, 2}, {-1, 0}} {
+		if _, err := doc.ExtractSegment(idx[0], idx[1]); err == nil {
+			t.Errorf("expected an error for track %d segment %d", idx[0], idx[1])
+		}
+	}
+}
+
+func TestRemoveNullIsland(t *testing.T) {
+	seg := Segment{Points: []Point{
+		{Latitude: 51.05, Longitude: 3.72},
+		{Latitude: 0, Longitude: 0},
+		{Latitude: 0, Longitude: 0},
+		{Latitude: 51.06, Longitude: 3.73},
+	}}
+	cleaned := seg.RemoveNullIsland()
+	if l := len(cleaned.Points); l != 2 {
+		t.Fatalf("got %d point(s); expected 2", l)
+	}
+	if cleaned.Points[1].Latitude != 51.06 {
+		t.Errorf("got %+v second point; expected 51.06, 3.73", cleaned.Points[1])
+	}
+
+	// A boat crossing the equator at the prime meridian keeps its point.
+	crossing := Segment{Points: []Point{
+		{Latitude: -0.01, Longitude: -0.01},
+		{Latitude: 0, Longitude: 0},
+		{Latitude: 0.01, Longitude: 0.01},
+	}}
+	if l := len(crossing.RemoveNullIsland().Points); l != 3 {
+		t.Errorf("got %d point(s) for a genuine crossing; expected 3", l)
+	}
+
+	only := Segment{Points: []Point{{}, {}}}
+	if l := len(only.RemoveNullIsland().Points); l != 2 {
+		t.Errorf("got %d point(s) for a segment at 0,0; expected 2", l)
+	}
+}
+
+func TestOverlappingTracks(t *testing.T) {
+	t0 := time.Date(2015, 12, 13, 18, 0, 0, 0, time.UTC)
+	track := func(from, to time.Duration) Track {
+		return Track{Segments: []Segment{{Points: []Point{
+			{Time: t0.Add(from)},
+			{Time: t0.Add(to)},
+		}}}}
+	}
+	doc := Document{Tracks: []Track{
+		track(0, time.Hour),
+		track(30*time.Minute, 90*time.Minute),
+		track(90*time.Minute, 2*time.Hour),
+		{Segments: []Segment{{Points: []Point{{Latitude: 1}}}}},
+		track(10*time.Minute, 20*time.Minute),
+	}}
+
+	expected := [][2]int{{0, 1}, {0, 4}}
+	if pairs := doc.OverlappingTracks(); !reflect.DeepEqual(pairs, expected) {
+		t.Errorf("got %v overlapping tracks; expected %v", pairs, expected)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	t0 := time.Date(2015, 12, 13, 18, 0, 0, 0, time.UTC)
+	first := Track{Name: "Before", Segments: []Segment{{Points: []Point{
+		{Latitude: 51, Longitude: 3.7, Time: t0.Add(time.Hour)},
+		{Latitude: 51.01, Longitude: 3.7, Time: t0.Add(time.Hour + time.Minute)},
+	}}}}.Document()
+	second := Track{Name: "After", Segments: []Segment{{Points: []Point{
+		{Latitude: 51.01, Longitude: 3.7, Time: t0},
+		{Latitude: 51.02, Longitude: 3.71, Time: t0.Add(time.Minute)},
+		{Latitude: 51.03, Longitude: 3.72, Time: t0.Add(2 * time.Minute)},
+	}}}}.Document()
+
+	merged := Merge(first, second)
+	if l := len(merged.Tracks); l != 2 {
+		t.Fatalf("got %d track(s); expected 2", l)
+	}
+	if merged.Tracks[0].Name != "Before" || merged.Tracks[1].Name != "After" {
+		t.Errorf("got tracks %q and %q; expected them in order", merged.Tracks[0].Name, merged.Tracks[1].Name)
+	}
+	if l := len(merged.AllPoints()); l != 5 {
+		t.Errorf("got %d point(s); expected 5", l)
+	}
+	if !merged.Metadata.Time.Equal(t0) {
+		t.Errorf("got %v metadata time; expected %v", merged.Metadata.Time, t0)
+	}
+	expected := Bounds{MinLatitude: 51, MinLongitude: 3.7, MaxLatitude: 51.03, MaxLongitude: 3.72}
+	if merged.Metadata.Bounds != expected {
+		t.Errorf("got %v bounds; expected %v", merged.Metadata.Bounds, expected)
+	}
+	if l := len(first.Tracks); l != 1 {
+		t.Errorf("got %d track(s) in the first document after merging; expected 1", l)
+	}
+
+	wpts := Document{
+		Metadata:  Metadata{Bounds: Bounds{MinLatitude: 50.9, MinLongitude: 3.6, MaxLatitude: 50.9, MaxLongitude: 3.6}},
+		Waypoints: []Waypoint{{Latitude: 50.9, Longitude: 3.6}},
+	}
+	rte := Document{
+		Metadata: Metadata{Bounds: Bounds{MinLatitude: 51.1, MinLongitude: 3.8, MaxLatitude: 51.1, MaxLongitude: 3.8}},
+		Routes:   []Route{{Points: []Point{{Latitude: 51.1, Longitude: 3.8}}}},
+	}
+	expected = Bounds{MinLatitude: 50.9, MinLongitude: 3.6, MaxLatitude: 51.1, MaxLongitude: 3.8}
+	if b := Merge(wpts, rte).Metadata.Bounds; b != expected {
+		t.Errorf("got %v bounds merging waypoints and routes; expected %v", b, expected)
+	}
+	wpts.Waypoints, rte.Routes = nil, nil
+	if b := Merge(wpts, rte).Metadata.Bounds; b != expected {
+		t.Errorf("got %v bounds merging documents without points; expected %v", b, expected)
+	}
+
+	if doc := Merge(); len(doc.Tracks) != 0 {
+		t.Errorf("got %d track(s) merging nothing; expected 0", len(doc.Tracks))
+	}
+}
+
+func TestSplitByTimeGap(t *testing.T) {
+	t0 := time.Date(2015, 12, 13, 18, 0, 0, 0, time.UTC)
+	track := Track{Name: "Trip", Segments: []Segment{{Points: []Point{
+		{Latitude: 51, Time: t0},
+		{Latitude: 51.001, Time: t0.Add(time.Minute)},
+		{Latitude: 51.002},
+		{Latitude: 51.003, Time: t0.Add(2 * time.Minute)},
+		{Latitude: 51.004, Time: t0.Add(14 * time.Hour)},
+		{Latitude: 51.005, Time: t0.Add(14*time.Hour + time.Minute)},
+	}}}}
+
+	tracks := track.SplitByTimeGap(time.Hour)
+	if l := len(tracks); l != 2 {
+		t.Fatalf("got %d track(s); expected 2", l)
+	}
+	for i, expected := range []int{4, 2} {
+		if l := len(tracks[i].Segments); l != 1 {
+			t.Fatalf("got %d segment(s) in track %d; expected 1", l, i)
+		}
+		if l := len(tracks[i].Segments[0].Points); l != expected {
+			t.Errorf("got %d point(s) in track %d; expected %d", l, i, expected)
+		}
+		if tracks[i].Name != "Trip" {
+			t.Errorf("got %q name for track %d; expected %q", tracks[i].Name, i, "Trip")
+		}
+	}
+
+	if l := len(track.SplitByTimeGap(24 * time.Hour)); l != 1 {
+		t.Errorf("got %d track(s) for a gap larger than the pause; expected 1", l)
+	}
+}
+
+func TestSplitByDistanceGap(t *testing.T) {
+	track := Track{Segments: []Segment{
+		{Points: []Point{{Latitude: 51}, {Latitude: 51.001}}},
+		{Points: []Point{{Latitude: 51.002}, {Latitude: 51.1}, {Latitude: 51.101}}},
+	}}
+
+	tracks := track.SplitByDistanceGap(1000)
+	if l := len(tracks); l != 2 {
+		t.Fatalf("got %d track(s); expected 2", l)
+	}
+	if l := len(tracks[0].Segments); l != 2 {
+		t.Errorf("got %d segment(s) in the first track; expected 2", l)
+	}
+	if l := len(tracks[1].Segments[0].Points); l != 2 {
+		t.Errorf("got %d point(s) in the second track; expected 2", l)
+	}
+}
+
+func TestRebaseTime(t *testing.T) {
+	t0 := time.Date(2015, 12, 13, 18, 0, 0, 0, time.UTC)
+	doc := FromPoints([]Point{
+		{},
+		{Time: t0},
+		{Time: t0.Add(10 * time.Second)},
+	})
+	doc.Metadata.Time = t0.Add(-time.Minute)
+	doc.Waypoints = []Waypoint{{Time: t0.Add(time.Hour)}}
+
+	epoch := time.Unix(0, 0).UTC()
+	doc.RebaseTime(epoch)
+
+	points := doc.AllPoints()
+	if !points[0].Time.IsZero() {
+		t.Errorf("got %v time for an untimed point; expected none", points[0].Time)
+	}
+	if !points[1].Time.Equal(epoch) {
+		t.Errorf("got %v start time; expected %v", points[1].Time, epoch)
+	}
+	if d := points[2].Time.Sub(points[1].Time); d != 10*time.Second {
+		t.Errorf("got %v interval; expected 10s", d)
+	}
+	if expected := epoch.Add(-time.Minute); !doc.Metadata.Time.Equal(expected) {
+		t.Errorf("got %v metadata time; expected %v", doc.Metadata.Time, expected)
+	}
+	if expected := epoch.Add(time.Hour); !doc.Waypoints[0].Time.Equal(expected) {
+		t.Errorf("got %v waypoint time; expected %v", doc.Waypoints[0].Time, expected)
+	}
+
+	untimed := FromPoints([]Point{{Latitude: 1}})
+	untimed.RebaseTime(epoch)
+	if !untimed.AllPoints()[0].Time.IsZero() {
+		t.Error("expected a document without timestamps to stay untimed")
+	}
+}
+
+func TestSegmentSimplify(t *testing.T) {
+	t0 := time.Date(2015, 12, 13, 18, 0, 0, 0, time.UTC)
+	var line Segment
+	for i := 0; i <= 100; i++ {
+		line.Points = append(line.Points, Point{
+			Latitude:  0.0001 * float64(i),
+			Longitude: 0.0001 * float64(i),
+			Time:      t0.Add(time.Duration(i) * time.Second),
+		})
+	}
+	simplified := line.Simplify(1)
+	if l := len(simplified.Points); l != 2 {
+		t.Fatalf("got %d point(s); expected 2", l)
+	}
+	if !reflect.DeepEqual(simplified.Points[1], line.Points[100]) {
+		t.Errorf("got %+v last point; expected %+v", simplified.Points[1], line.Points[100])
+	}
+
+	// An out-and-back must keep its turnaround point.
+	outAndBack := Segment{Points: []Point{
+		{Longitude: 0}, {Longitude: 0.001}, {Longitude: 0.002},
+		{Longitude: 0.001}, {Longitude: 0.0005},
+	}}
+	if l := len(outAndBack.Simplify(1).Points); l != 3 {
+		t.Errorf("got %d point(s) for an out-and-back; expected 3", l)
+	}
+
+	// A corner is kept.
+	corner := Segment{Points: []Point{
+		{Longitude: 0}, {Longitude: 0.001}, {Longitude: 0.002},
+		{Latitude: 0.001, Longitude: 0.002}, {Latitude: 0.002, Longitude: 0.002},
+	}}
+	if l := len(corner.Simplify(1).Points); l != 3 {
+		t.Errorf("got %d point(s) for a corner; expected 3", l)
+	}
+
+	short := Segment{Points: []Point{{Latitude: 1}, {Latitude: 2}}}
+	if !reflect.DeepEqual(short.Simplify(1000000), short) {
+		t.Error("expected a segment with two points to be left as is")
+	}
+}
@@ -0,0 +1,410 @@
+package gpx
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// creator is the value of the creator attribute of encoded documents.
+const creator = "github.com/pieterclaerhout/gpx"
+
+// xmlNS is the namespace the encoding/xml decoder assigns to names using the
+// reserved xml prefix.
+const xmlNS = "http://www.w3.org/XML/1998/namespace"
+
+// knownPrefixes holds the conventional prefixes of well-known extension
+// namespaces.
+var knownPrefixes = map[string]string{
+	GarminTrackPointExtensionNS: "gpxtpx",
+	GarminPowerExtensionNS:      "gpxpx",
+	GarminGpxExtensionsNS:       "gpxx",
+	GpxdataNS:                   "gpxdata",
+}
+
+// An Encoder writes GPX 1.1 documents to an output stream.
+type Encoder struct {
+	w      io.Writer
+	prefix string
+	indent string
+}
+
+// An EncoderOption configures an Encoder.
+type EncoderOption func(*Encoder)
+
+// WithIndent makes the encoder pretty-print its output, like
+// xml.Encoder.Indent: every element begins on a new line, starting with
+// prefix followed by one or more copies of indent according to the nesting
+// depth. The contents of extensions are written as they were decoded. By
+// default the output is compact.
+func WithIndent(prefix, indent string) EncoderOption {
+	return func(e *Encoder) {
+		e.prefix = prefix
+		e.indent = indent
+	}
+}
+
+// NewEncoder creates a new encoder writing to w configured by opts.
+func NewEncoder(w io.Writer, opts ...EncoderOption) *Encoder {
+	e := &Encoder{w: w}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Encode writes doc as a GPX 1.1 document. Extensions are written back from
+// their raw tokens, with the namespaces they use declared on the root
+// element.
+func (e *Encoder) Encode(doc Document) error {
+	ew := &encodeWriter{
+		w:      bufio.NewWriter(e.w),
+		pretty: e.prefix != "" || e.indent != "",
+		prefix: e.prefix,
+		indent: e.indent,
+		ns:     map[string]string{nsGPX11: "", xmlNS: "xml"},
+	}
+
+	attrs := []string{"version", "1.1", "creator", creator, "xmlns", nsGPX11}
+	for _, space := range ew.collectNamespaces(doc) {
+		attrs = append(attrs, "xmlns:"+ew.ns[space], space)
+	}
+
+	ew.writeString(`<?xml version="1.0" encoding="UTF-8"?>`)
+	if !ew.pretty {
+		ew.writeString("\n")
+	}
+	ew.start("gpx", attrs...)
+	ew.metadata(doc.Metadata)
+	for _, w := range doc.Waypoints {
+		ew.waypoint(w)
+	}
+	for _, r := range doc.Routes {
+		ew.route(r)
+	}
+	for _, t := range doc.Tracks {
+		ew.track(t)
+	}
+	ew.end("gpx")
+	ew.writeString("\n")
+
+	if ew.err != nil {
+		return ew.err
+	}
+	return ew.w.Flush()
+}
+
+// An encodeWriter writes GPX elements, keeping the first error that occurs.
+type encodeWriter struct {
+	w      *bufio.Writer
+	err    error
+	pretty bool
+	prefix string
+	indent string
+	depth  int
+
+	// ns maps namespaces used by extensions to their prefixes.
+	ns map[string]string
+}
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;", "\r", "&#xD;", "\n", "&#xA;", "\t", "&#x9;")
+)
+
+// collectNamespaces assigns a prefix to every namespace used by the
+// document's extensions and returns them in order of first use.
+func (ew *encodeWriter) collectNamespaces(doc Document) []string {
+	var spaces []string
+	add := func(space string) {
+		if space == "" {
+			return
+		}
+		if _, ok := ew.ns[space]; ok {
+			return
+		}
+		prefix, ok := knownPrefixes[space]
+		if !ok {
+			prefix = fmt.Sprintf("ns%d", len(spaces)+1)
+		}
+		ew.ns[space] = prefix
+		spaces = append(spaces, space)
+	}
+	addTokens := func(tokens []xml.Token) {
+		for _, tok := range tokens {
+			se, ok := tok.(xml.StartElement)
+			if !ok {
+				continue
+			}
+			add(se.Name.Space)
+			for _, a := range se.Attr {
+				if a.Name.Space != "xmlns" && !(a.Name.Space == "" && a.Name.Local == "xmlns") {
+					add(a.Name.Space)
+				}
+			}
+		}
+	}
+
+	addTokens(doc.Metadata.Extensions)
+	for _, w := range doc.Waypoints {
+		addTokens(w.Extensions)
+	}
+	for _, r := range doc.Routes {
+		for _, p := range r.Points {
+			addTokens(p.Extensions)
+		}
+	}
+	for _, t := range doc.Tracks {
+		addTokens(t.Extensions)
+		for _, s := range t.Segments {
+			for _, p := range s.Points {
+				addTokens(p.Extensions)
+			}
+		}
+	}
+	return spaces
+}
+
+func (ew *encodeWriter) writeString(s string) {
+	if ew.err == nil {
+		_, ew.err = ew.w.WriteString(s)
+	}
+}
+
+func (ew *encodeWriter) newline() {
+	if ew.pretty {
+		ew.writeString("\n" + ew.prefix + strings.Repeat(ew.indent, ew.depth))
+	}
+}
+
+func (ew *encodeWriter) tag(name string, attrs []string, selfClosing bool) {
+	ew.writeString("<" + name)
+	for i := 0; i+1 < len(attrs); i += 2 {
+		ew.writeString(" " + attrs[i] + `="` + attrEscaper.Replace(attrs[i+1]) + `"`)
+	}
+	if selfClosing {
+		ew.writeString("/>")
+	} else {
+		ew.writeString(">")
+	}
+}
+
+// start writes a start tag with attrs given as name/value pairs.
+func (ew *encodeWriter) start(name string, attrs ...string) {
+	ew.newline()
+	ew.tag(name, attrs, false)
+	ew.depth++
+}
+
+func (ew *encodeWriter) end(name string) {
+	ew.depth--
+	ew.newline()
+	ew.writeString("</" + name + ">")
+}
+
+// empty writes a self-closing element with attrs given as name/value pairs.
+func (ew *encodeWriter) empty(name string, attrs ...string) {
+	ew.newline()
+	ew.tag(name, attrs, true)
+}
+
+// text writes an element holding s, unless s is empty.
+func (ew *encodeWriter) text(name, s string) {
+	if s == "" {
+		return
+	}
+	ew.newline()
+	ew.writeString("<" + name + ">" + textEscaper.Replace(s) + "</" + name + ">")
+}
+
+// time writes an element holding t, unless t is zero.
+func (ew *encodeWriter) time(name string, t time.Time) {
+	if !t.IsZero() {
+		ew.text(name, t.Format(time.RFC3339Nano))
+	}
+}
+
+// extensions writes an <extensions> element holding tokens, unless there
+// are none. The tokens are written as is, without indentation, so they
+// decode to the same tokens.
+func (ew *encodeWriter) extensions(tokens []xml.Token) {
+	if len(tokens) == 0 {
+		return
+	}
+	ew.newline()
+	ew.writeString("<extensions>")
+	for _, tok := range tokens {
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			attrs := make([]string, 0, 2*len(tok.Attr))
+			for _, a := range tok.Attr {
+				attrs = append(attrs, ew.attrName(a.Name), a.Value)
+			}
+			ew.tag(ew.qualify(tok.Name), attrs, false)
+		case xml.EndElement:
+			ew.writeString("</" + ew.qualify(tok.Name) + ">")
+		case xml.CharData:
+			ew.writeString(textEscaper.Replace(string(tok)))
+		case xml.Comment:
+			ew.writeString("<!--" + string(tok) + "-->")
+		case xml.ProcInst:
+			ew.writeString("<?" + tok.Target + " " + string(tok.Inst) + "?>")
+		case xml.Directive:
+			ew.writeString("<!" + string(tok) + ">")
+		}
+	}
+	ew.writeString("</extensions>")
+}
+
+// qualify returns name prefixed with the prefix of its namespace.
+func (ew *encodeWriter) qualify(name xml.Name) string {
+	if prefix := ew.ns[name.Space]; prefix != "" {
+		return prefix + ":" + name.Local
+	}
+	return name.Local
+}
+
+// attrName returns the name of an attribute, keeping namespace
+// declarations as they were.
+func (ew *encodeWriter) attrName(name xml.Name) string {
+	if name.Space == "xmlns" {
+		return "xmlns:" + name.Local
+	}
+	return ew.qualify(name)
+}
+
+func (ew *encodeWriter) metadata(m Metadata) {
+	if m.Name == "" && m.Description == "" && m.Author == (Person{}) &&
+		m.Copyright == (Copyright{}) && m.Link == (Link{}) && m.Time.IsZero() &&
+		m.Keywords == "" && m.Bounds == (Bounds{}) && len(m.Extensions) == 0 {
+		return
+	}
+
+	ew.start("metadata")
+	ew.text("name", m.Name)
+	ew.text("desc", m.Description)
+	if m.Author != (Person{}) {
+		ew.start("author")
+		ew.text("name", m.Author.Name)
+		if m.Author.Email != (Email{}) {
+			ew.empty("email", "id", m.Author.Email.ID, "domain", m.Author.Email.Domain)
+		}
+		ew.link(m.Author.Link)
+		ew.end("author")
+	}
+	if m.Copyright != (Copyright{}) {
+		ew.start("copyright", "author", m.Copyright.Author)
+		if m.Copyright.Year != 0 {
+			ew.text("year", strconv.Itoa(m.Copyright.Year))
+		}
+		ew.text("license", m.Copyright.License)
+		ew.end("copyright")
+	}
+	ew.link(m.Link)
+	ew.time("time", m.Time)
+	ew.text("keywords", m.Keywords)
+	if m.Bounds != (Bounds{}) {
+		ew.empty("bounds",
+			"minlat", formatFloat(m.Bounds.MinLatitude),
+			"minlon", formatFloat(m.Bounds.MinLongitude),
+			"maxlat", formatFloat(m.Bounds.MaxLatitude),
+			"maxlon", formatFloat(m.Bounds.MaxLongitude),
+		)
+	}
+	ew.extensions(m.Extensions)
+	ew.end("metadata")
+}
+
+// link writes a <link> element, unless l is empty.
+func (ew *encodeWriter) link(l Link) {
+	if l == (Link{}) {
+		return
+	}
+	ew.start("link", "href", l.Href)
+	ew.text("text", l.Text)
+	ew.text("type", l.Type)
+	ew.end("link")
+}
+
+func (ew *encodeWriter) waypoint(w Waypoint) {
+	ew.start("wpt", "lat", formatFloat(w.Latitude), "lon", formatFloat(w.Longitude))
+	if w.hasElevation() {
+		ew.text("ele", formatFloat(w.Elevation))
+	}
+	ew.time("time", w.Time)
+	ew.text("name", w.Name)
+	ew.text("desc", w.Description)
+	ew.text("sym", w.Symbol)
+	ew.text("type", w.Type)
+	ew.extensions(w.Extensions)
+	ew.end("wpt")
+}
+
+func (ew *encodeWriter) route(r Route) {
+	ew.start("rte")
+	ew.text("name", r.Name)
+	for _, p := range r.Points {
+		ew.point("rtept", p)
+	}
+	ew.end("rte")
+}
+
+func (ew *encodeWriter) track(t Track) {
+	ew.start("trk")
+	ew.text("name", t.Name)
+	ew.text("type", t.Type)
+	ew.extensions(t.Extensions)
+	for _, s := range t.Segments {
+		ew.start("trkseg")
+		for _, p := range s.Points {
+			ew.point("trkpt", p)
+		}
+		ew.end("trkseg")
+	}
+	ew.end("trk")
+}
+
+func (ew *encodeWriter) point(name string, p Point) {
+	ew.start(name, "lat", formatRaw(p.Latitude, p.RawLatitude), "lon", formatRaw(p.Longitude, p.RawLongitude))
+	if p.hasElevation() {
+		ew.text("ele", formatRaw(p.Elevation, p.RawElevation))
+	}
+	ew.time("time", p.Time)
+	ew.text("name", p.Name)
+	ew.text("sym", p.Symbol)
+	ew.text("type", p.Type)
+	ew.text("fix", p.Fix)
+	if p.Satellites != 0 {
+		ew.text("sat", strconv.FormatUint(uint64(p.Satellites), 10))
+	}
+	for _, dop := range []struct {
+		name  string
+		value float64
+	}{{"hdop", p.HDOP}, {"vdop", p.VDOP}, {"pdop", p.PDOP}} {
+		if dop.value != 0 {
+			ew.text(dop.name, formatFloat(dop.value))
+		}
+	}
+	ew.extensions(p.Extensions)
+	ew.end(name)
+}
+
+// formatRaw returns raw if it is the text f was decoded from, and formats
+// f otherwise, so values changed after decoding aren't written stale.
+func formatRaw(f float64, raw string) string {
+	if raw != "" {
+		if r, err := strconv.ParseFloat(raw, 64); err == nil && r == f {
+			return raw
+		}
+	}
+	return formatFloat(f)
+}
+
+// formatFloat formats f with the fewest digits that parse back to f.
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
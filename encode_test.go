@@ -0,0 +1,191 @@
+package gpx
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func decodeFile(t *testing.T, name string) Document {
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	doc, err := NewDecoder(f).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func roundTrip(t *testing.T, doc Document, opts ...EncoderOption) (Document, string) {
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf, opts...).Encode(doc); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	decoded, err := NewDecoder(&buf).Decode()
+	if err != nil {
+		t.Fatalf("decoding encoded document: %s\n%s", err, out)
+	}
+	return decoded, out
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	doc := decodeFile(t, "test/test.gpx")
+
+	decoded, out := roundTrip(t, doc)
+	if !reflect.DeepEqual(decoded, doc) {
+		t.Errorf("got %+v after a round trip; expected %+v", decoded, doc)
+	}
+	for _, s := range []string{
+		`<gpx version="1.1"`,
+		`xmlns="http://www.topografix.com/GPX/1/1"`,
+		`xmlns:gpxtpx="http://www.garmin.com/xmlschemas/TrackPointExtension/v1"`,
+		`<gpxtpx:hr>126</gpxtpx:hr>`,
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("expected %q in encoded document", s)
+		}
+	}
+	if strings.Contains(out, "\n  <trk>") {
+		t.Error("expected compact output by default")
+	}
+}
+
+func TestEncodePreserveRaw(t *testing.T) {
+	f, err := os.Open("test/test.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	d := NewDecoder(f)
+	d.PreserveRaw = true
+	doc, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.Tracks[0].Segments[0].Points[1].Latitude = 49.5
+
+	_, out := roundTrip(t, doc)
+	for _, s := range []string{
+		`<trkpt lat="49.3973693847656250" lon="11.1259574890136719"><ele>346.874267578125</ele>`,
+		`<trkpt lat="49.5" lon=`,
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("expected %q in encoded document", s)
+		}
+	}
+}
+
+func TestEncodeWithIndent(t *testing.T) {
+	doc := decodeFile(t, "test/test.gpx")
+
+	decoded, out := roundTrip(t, doc, WithIndent("", "  "))
+	if !reflect.DeepEqual(decoded, doc) {
+		t.Errorf("got %+v after an indented round trip; expected %+v", decoded, doc)
+	}
+	if !strings.Contains(out, "\n  <trk>\n    <name>Running</name>") {
+		t.Errorf("expected indented output; got\n%s", out)
+	}
+}
+
+func TestEncodeElevationWithoutFlag(t *testing.T) {
+	doc := FromPoints([]Point{
+		{Latitude: 49.39, Longitude: 11.12, Elevation: 100},
+		{Latitude: 49.40, Longitude: 11.13},
+	})
+	doc.Waypoints = []Waypoint{{Latitude: 49.41, Longitude: 11.14, Elevation: 120}}
+
+	decoded, out := roundTrip(t, doc)
+	if strings.Count(out, "<ele>") != 2 {
+		t.Errorf("got %d <ele> element(s); expected 2 in\n%s", strings.Count(out, "<ele>"), out)
+	}
+	points := decoded.AllPoints()
+	if !points[0].HasElevation || points[0].Elevation != 100 {
+		t.Errorf("got %v elevation (%t); expected 100", points[0].Elevation, points[0].HasElevation)
+	}
+	if points[1].HasElevation {
+		t.Error("expected a point without elevation to stay without one")
+	}
+	if w := decoded.Waypoints[0]; !w.HasElevation || w.Elevation != 120 {
+		t.Errorf("got %v waypoint elevation (%t); expected 120", w.Elevation, w.HasElevation)
+	}
+}
+
+func TestEncodeGPX10(t *testing.T) {
+	doc := decodeFile(t, "test/gpx10.gpx")
+
+	decoded, out := roundTrip(t, doc)
+	if decoded.OriginalVersion != "1.1" {
+		t.Errorf("got original version %q after re-encoding; expected 1.1", decoded.OriginalVersion)
+	}
+	decoded.OriginalVersion = doc.OriginalVersion
+	if !reflect.DeepEqual(decoded, doc) {
+		t.Errorf("got %+v after a round trip; expected %+v", decoded, doc)
+	}
+	if strings.Contains(out, "GPX/1/0") {
+		t.Error("expected a GPX 1.1 document")
+	}
+}
+
+func TestEncodeEscaping(t *testing.T) {
+	doc := FromPoints([]Point{{Latitude: 1.5, Longitude: -2.25}})
+	doc.Metadata.Name = `Fish & "Chips" <run>`
+	doc.Tracks[0].Name = "Line\nbreak"
+
+	decoded, _ := roundTrip(t, doc)
+	doc.OriginalVersion = "1.1"
+	if !reflect.DeepEqual(decoded, doc) {
+		t.Errorf("got %+v after a round trip; expected %+v", decoded, doc)
+	}
+}
+
+func TestEncodeCustomExtension(t *testing.T) {
+	const data = `<gpx version="1.1" xmlns="http://www.topografix.com/GPX/1/1"><trk><trkseg><trkpt lat="1" lon="2"><extensions><my:foo xmlns:my="urn:example" my:unit="m">12 &amp; more</my:foo><!-- note --></extensions></trkpt></trkseg></trk></gpx>`
+
+	doc, err := NewDecoder(strings.NewReader(data)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, _ := roundTrip(t, doc)
+	if !reflect.DeepEqual(decoded, doc) {
+		t.Errorf("got %+v after a round trip; expected %+v", decoded, doc)
+	}
+}
+
+func TestEncodeWaypoints(t *testing.T) {
+	doc := decodeFile(t, "test/waypoints.gpx")
+
+	decoded, _ := roundTrip(t, doc)
+	if !reflect.DeepEqual(decoded, doc) {
+		t.Errorf("got %+v after a round trip; expected %+v", decoded, doc)
+	}
+}
+
+func TestEncodeRoutes(t *testing.T) {
+	for _, name := range []string{"test/routes.gpx", "test/course.gpx"} {
+		doc := decodeFile(t, name)
+
+		decoded, _ := roundTrip(t, doc)
+		if !reflect.DeepEqual(decoded, doc) {
+			t.Errorf("got %+v after a round trip of %s; expected %+v", decoded, name, doc)
+		}
+	}
+}
+
+func TestEncodeQualityFields(t *testing.T) {
+	doc := decodeFile(t, "test/quality.gpx")
+
+	decoded, _ := roundTrip(t, doc)
+	if !reflect.DeepEqual(decoded, doc) {
+		t.Errorf("got %+v after a round trip; expected %+v", decoded, doc)
+	}
+}
@@ -3,6 +3,11 @@ package gpx
 import (
 	"encoding/xml"
 	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
 )
 
 var (
@@ -15,6 +20,8 @@ type GarminTrackPointExtension struct {
 	AirTemp   float64 // Air temperature (Celsius)
 	WaterTemp float64 // Water temperature (Celsius)
 	Depth     float64 // Diving depth (meters)
+	Speed     float64 // Speed (meters per second)
+	Course    float64 // Course (degrees)
 	HeartRate uint    // Heart rate (beats per minute)
 	Cadence   uint    // Cadence (revs per minute)
 }
@@ -73,6 +80,291 @@ func ParseGarminTrackPointExtension(tokens []xml.Token) (e GarminTrackPointExten
 					return e, err
 				}
 				e.Depth = depth
+			case "speed":
+				speed, err := ts.consumeFloat()
+				if err != nil {
+					return e, err
+				}
+				e.Speed = speed
+			case "course":
+				course, err := ts.consumeFloat()
+				if err != nil {
+					return e, err
+				}
+				e.Course = course
+			default:
+				ts.skipTag()
+			}
+		case xml.EndElement:
+			return e, nil
+		}
+	}
+}
+
+// GarminPowerExtension is Garmin’s Power extension defined by
+// https://www8.garmin.com/xmlschemas/PowerExtensionv1.xsd
+type GarminPowerExtension struct {
+	Power uint // Power (watts)
+}
+
+const GarminPowerExtensionNS = "http://www.garmin.com/xmlschemas/PowerExtension/v1"
+
+// ParseGarminPowerExtension tries to parse Garmin’s Power extension from a
+// point’s extensions tokens.
+func ParseGarminPowerExtension(tokens []xml.Token) (e GarminPowerExtension, err error) {
+	ts := tokenStream{&sliceTokener{tokens: tokens}}
+
+	if !findExtension(ts, GarminPowerExtensionNS, "PowerInWatts") {
+		return e, ErrNoSuchExtension
+	}
+
+	power, err := ts.consumeInt()
+	if err != nil {
+		return e, err
+	}
+	e.Power = uint(power)
+	return e, nil
+}
+
+// GarminWaypointExtension is Garmin’s Waypoint extension defined by
+// https://www8.garmin.com/xmlschemas/GpxExtensionsv3.xsd
+type GarminWaypointExtension struct {
+	Proximity   float64 // Proximity alarm distance (meters)
+	DisplayMode string  // How the waypoint is displayed, e.g. "SymbolAndName"
+	Categories  []string
+	Address     GarminAddress
+	PhoneNumber string
+}
+
+// GarminAddress is an address of Garmin’s Waypoint extension.
+type GarminAddress struct {
+	StreetAddress []string
+	City          string
+	State         string
+	Country       string
+	PostalCode    string
+}
+
+const GarminGpxExtensionsNS = "http://www.garmin.com/xmlschemas/GpxExtensions/v3"
+
+// ParseGarminWaypointExtension tries to parse Garmin’s Waypoint extension
+// from a waypoint’s extensions tokens.
+func ParseGarminWaypointExtension(tokens []xml.Token) (e GarminWaypointExtension, err error) {
+	ts := tokenStream{&sliceTokener{tokens: tokens}}
+
+	if !findExtension(ts, GarminGpxExtensionsNS, "WaypointExtension") {
+		return e, ErrNoSuchExtension
+	}
+
+	for {
+		tok, err := ts.Token()
+		if err != nil {
+			return e, err
+		}
+		switch tok.(type) {
+		case xml.StartElement:
+			se := tok.(xml.StartElement)
+			if se.Name.Space != GarminGpxExtensionsNS {
+				ts.skipTag()
+				continue
+			}
+			switch se.Name.Local {
+			case "Proximity":
+				proximity, err := ts.consumeFloat()
+				if err != nil {
+					return e, err
+				}
+				e.Proximity = proximity
+			case "DisplayMode":
+				mode, err := ts.consumeString()
+				if err != nil {
+					return e, err
+				}
+				e.DisplayMode = mode
+			case "Categories":
+				categories, err := consumeGarminCategories(ts)
+				if err != nil {
+					return e, err
+				}
+				e.Categories = categories
+			case "Address":
+				address, err := consumeGarminAddress(ts)
+				if err != nil {
+					return e, err
+				}
+				e.Address = address
+			case "PhoneNumber":
+				phone, err := ts.consumeString()
+				if err != nil {
+					return e, err
+				}
+				e.PhoneNumber = phone
+			default:
+				ts.skipTag()
+			}
+		case xml.EndElement:
+			return e, nil
+		}
+	}
+}
+
+func consumeGarminCategories(ts tokenStream) (categories []string, err error) {
+	for {
+		tok, err := ts.Token()
+		if err != nil {
+			return categories, err
+		}
+		switch tok.(type) {
+		case xml.StartElement:
+			se := tok.(xml.StartElement)
+			if se.Name.Space != GarminGpxExtensionsNS || se.Name.Local != "Category" {
+				ts.skipTag()
+				continue
+			}
+			category, err := ts.consumeString()
+			if err != nil {
+				return categories, err
+			}
+			categories = append(categories, category)
+		case xml.EndElement:
+			return categories, nil
+		}
+	}
+}
+
+func consumeGarminAddress(ts tokenStream) (address GarminAddress, err error) {
+	for {
+		tok, err := ts.Token()
+		if err != nil {
+			return address, err
+		}
+		switch tok.(type) {
+		case xml.StartElement:
+			se := tok.(xml.StartElement)
+			if se.Name.Space != GarminGpxExtensionsNS {
+				ts.skipTag()
+				continue
+			}
+			switch se.Name.Local {
+			case "StreetAddress":
+				street, err := ts.consumeString()
+				if err != nil {
+					return address, err
+				}
+				address.StreetAddress = append(address.StreetAddress, street)
+			case "City":
+				city, err := ts.consumeString()
+				if err != nil {
+					return address, err
+				}
+				address.City = city
+			case "State":
+				state, err := ts.consumeString()
+				if err != nil {
+					return address, err
+				}
+				address.State = state
+			case "Country":
+				country, err := ts.consumeString()
+				if err != nil {
+					return address, err
+				}
+				address.Country = country
+			case "PostalCode":
+				postalCode, err := ts.consumeString()
+				if err != nil {
+					return address, err
+				}
+				address.PostalCode = postalCode
+			default:
+				ts.skipTag()
+			}
+		case xml.EndElement:
+			return address, nil
+		}
+	}
+}
+
+// GarminRoutePointExtension is Garmin’s RoutePoint extension defined by
+// https://www8.garmin.com/xmlschemas/GpxExtensionsv3.xsd. It holds the
+// points the device calculated between a route point and the next one.
+type GarminRoutePointExtension struct {
+	Subclass string
+	Points   []GarminRoutePoint
+}
+
+// GarminRoutePoint is a calculated point of Garmin’s RoutePoint extension.
+type GarminRoutePoint struct {
+	Latitude  float64
+	Longitude float64
+	Subclass  string
+}
+
+// CoursePoint is a point of a Garmin course, such as a climb or a turn
+// shown by navigation apps. Its name, symbol, type and position come from
+// the route point; Subclass and Points from its RoutePoint extension.
+type CoursePoint struct {
+	Name      string
+	Symbol    string
+	Type      string
+	Latitude  float64
+	Longitude float64
+	Subclass  string
+	Points    []GarminRoutePoint // Calculated points up to the next course point
+}
+
+// ParseGarminCoursePoint tries to parse the course point of route point p.
+// ErrNoSuchExtension is returned if p has no RoutePoint extension.
+func ParseGarminCoursePoint(p Point) (CoursePoint, error) {
+	e, err := ParseGarminRoutePointExtension(p.Extensions)
+	if err != nil {
+		return CoursePoint{}, err
+	}
+	return CoursePoint{
+		Name:      p.Name,
+		Symbol:    p.Symbol,
+		Type:      p.Type,
+		Latitude:  p.Latitude,
+		Longitude: p.Longitude,
+		Subclass:  e.Subclass,
+		Points:    e.Points,
+	}, nil
+}
+
+// ParseGarminRoutePointExtension tries to parse Garmin’s RoutePoint
+// extension from a route point’s extensions tokens.
+func ParseGarminRoutePointExtension(tokens []xml.Token) (e GarminRoutePointExtension, err error) {
+	ts := tokenStream{&sliceTokener{tokens: tokens}}
+
+	if !findExtension(ts, GarminGpxExtensionsNS, "RoutePointExtension") {
+		return e, ErrNoSuchExtension
+	}
+
+	for {
+		tok, err := ts.Token()
+		if err != nil {
+			return e, err
+		}
+		switch tok.(type) {
+		case xml.StartElement:
+			se := tok.(xml.StartElement)
+			if se.Name.Space != GarminGpxExtensionsNS {
+				ts.skipTag()
+				continue
+			}
+			switch se.Name.Local {
+			case "Subclass":
+				subclass, err := ts.consumeString()
+				if err != nil {
+					return e, err
+				}
+				e.Subclass = subclass
+			case "rpt":
+				rpt, err := consumeGarminRoutePoint(ts, se)
+				if err != nil {
+					return e, err
+				}
+				e.Points = append(e.Points, rpt)
 			default:
 				ts.skipTag()
 			}
@@ -82,6 +374,239 @@ func ParseGarminTrackPointExtension(tokens []xml.Token) (e GarminTrackPointExten
 	}
 }
 
+func consumeGarminRoutePoint(ts tokenStream, se xml.StartElement) (rpt GarminRoutePoint, err error) {
+	for _, a := range se.Attr {
+		switch a.Name.Local {
+		case "lat":
+			lat, err := strconv.ParseFloat(a.Value, 64)
+			if err != nil {
+				return rpt, fmt.Errorf("gpx: invalid <gpxx:rpt> lat: %s", err)
+			}
+			rpt.Latitude = lat
+		case "lon":
+			lon, err := strconv.ParseFloat(a.Value, 64)
+			if err != nil {
+				return rpt, fmt.Errorf("gpx: invalid <gpxx:rpt> lon: %s", err)
+			}
+			rpt.Longitude = lon
+		}
+	}
+
+	for {
+		tok, err := ts.Token()
+		if err != nil {
+			return rpt, err
+		}
+		switch tok.(type) {
+		case xml.StartElement:
+			se := tok.(xml.StartElement)
+			if se.Name.Space == GarminGpxExtensionsNS && se.Name.Local == "Subclass" {
+				subclass, err := ts.consumeString()
+				if err != nil {
+					return rpt, err
+				}
+				rpt.Subclass = subclass
+				continue
+			}
+			ts.skipTag()
+		case xml.EndElement:
+			return rpt, nil
+		}
+	}
+}
+
+// Lap is a lap recorded by a device.
+type Lap struct {
+	Index     int
+	StartTime time.Time
+	Duration  time.Duration
+	Distance  float64 // Distance (meters)
+	Calories  uint
+}
+
+const GpxdataNS = "http://www.cluetrust.com/XML/GPXDATA/1/0"
+
+// ParseGpxdataLaps tries to parse the <gpxdata:lap> elements of the gpxdata
+// extension from a track’s extensions tokens.
+func ParseGpxdataLaps(tokens []xml.Token) (laps []Lap, err error) {
+	ts := tokenStream{&sliceTokener{tokens: tokens}}
+
+	for findExtension(ts, GpxdataNS, "lap") {
+		lap, err := consumeGpxdataLap(ts)
+		if err != nil {
+			return laps, err
+		}
+		laps = append(laps, lap)
+	}
+
+	if len(laps) == 0 {
+		return nil, ErrNoSuchExtension
+	}
+	return laps, nil
+}
+
+func consumeGpxdataLap(ts tokenStream) (lap Lap, err error) {
+	for {
+		tok, err := ts.Token()
+		if err != nil {
+			return lap, err
+		}
+		switch tok.(type) {
+		case xml.StartElement:
+			se := tok.(xml.StartElement)
+			if se.Name.Space != GpxdataNS {
+				ts.skipTag()
+				continue
+			}
+			switch se.Name.Local {
+			case "index":
+				index, err := ts.consumeInt()
+				if err != nil {
+					return lap, err
+				}
+				lap.Index = index
+			case "startTime":
+				t, err := ts.consumeTime()
+				if err != nil {
+					return lap, err
+				}
+				lap.StartTime = t
+			case "elapsedTime":
+				secs, err := ts.consumeFloat()
+				if err != nil {
+					return lap, err
+				}
+				lap.Duration = time.Duration(secs * float64(time.Second))
+			case "distance":
+				distance, err := ts.consumeFloat()
+				if err != nil {
+					return lap, err
+				}
+				lap.Distance = distance
+			case "calories":
+				calories, err := ts.consumeInt()
+				if err != nil {
+					return lap, err
+				}
+				lap.Calories = uint(calories)
+			default:
+				ts.skipTag()
+			}
+		case xml.EndElement:
+			return lap, nil
+		}
+	}
+}
+
+// RouteInstruction is a turn-by-turn cue embedded in a route point's
+// extensions by routing tools.
+type RouteInstruction struct {
+	Text string // Instruction text, e.g. "Turn left onto Main Street"
+	Turn string // Turn type as written by the tool, e.g. "TL" or "left"
+}
+
+// ParseRouteInstruction tries to parse a turn-by-turn cue from a route
+// point’s extensions tokens. The cue is read from <instruction> and <turn>
+// elements at the top level of the extensions, regardless of their
+// namespace, as routing tools each use their own.
+func ParseRouteInstruction(tokens []xml.Token) (e RouteInstruction, err error) {
+	ts := tokenStream{&sliceTokener{tokens: tokens}}
+
+	found := false
+	for {
+		tok, err := ts.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return e, err
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch se.Name.Local {
+		case "instruction":
+			text, err := ts.consumeString()
+			if err != nil {
+				return e, err
+			}
+			e.Text = text
+			found = true
+		case "turn":
+			turn, err := ts.consumeString()
+			if err != nil {
+				return e, err
+			}
+			e.Turn = turn
+			found = true
+		default:
+			ts.skipTag()
+		}
+	}
+
+	if !found {
+		return e, ErrNoSuchExtension
+	}
+	return e, nil
+}
+
+// ParseExtensionValues reads the first extension in namespace ns from an
+// element’s extensions tokens and returns the text of its leaf elements,
+// keyed by their local names. Nested elements are descended into; when a
+// local name occurs more than once, the last value wins.
+func ParseExtensionValues(tokens []xml.Token, ns string) (map[string]string, error) {
+	ts := tokenStream{&sliceTokener{tokens: tokens}}
+
+	for {
+		tok, err := ts.Token()
+		if err == io.EOF {
+			return nil, ErrNoSuchExtension
+		}
+		if err != nil {
+			return nil, err
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if se.Name.Space != ns {
+			ts.skipTag()
+			continue
+		}
+		values := make(map[string]string)
+		if err := consumeExtensionValues(ts, se, values); err != nil {
+			return nil, err
+		}
+		return values, nil
+	}
+}
+
+func consumeExtensionValues(ts tokenStream, se xml.StartElement, values map[string]string) error {
+	var text string
+	leaf := true
+	for {
+		tok, err := ts.Token()
+		if err != nil {
+			return err
+		}
+		switch tok.(type) {
+		case xml.CharData:
+			text += string(tok.(xml.CharData))
+		case xml.StartElement:
+			leaf = false
+			if err := consumeExtensionValues(ts, tok.(xml.StartElement), values); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			if leaf {
+				values[se.Name.Local] = strings.TrimSpace(text)
+			}
+			return nil
+		}
+	}
+}
+
 func findExtension(ts tokenStream, space, local string) bool {
 	for {
 		tok, err := ts.Token()
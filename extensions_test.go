@@ -1,9 +1,12 @@
 package gpx
 
 import (
+	"encoding/xml"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestNonexistantExtension(t *testing.T) {
@@ -41,3 +44,269 @@ func TestGarminTrackPointExtension(t *testing.T) {
 		t.Errorf("got %#v extension; expected %#v", ext, expectedExt)
 	}
 }
+
+func TestGarminTrackPointExtensionSpeedCourse(t *testing.T) {
+	tokens := extensionTokens(`<gpxtpx:TrackPointExtension xmlns:gpxtpx="http://www.garmin.com/xmlschemas/TrackPointExtension/v1"><gpxtpx:hr>140</gpxtpx:hr><gpxtpx:speed>4.25</gpxtpx:speed><gpxtpx:course>271.5</gpxtpx:course><gpxtpx:bearing>12</gpxtpx:bearing></gpxtpx:TrackPointExtension>`)
+
+	ext, err := ParseGarminTrackPointExtension(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedExt := GarminTrackPointExtension{
+		HeartRate: 140,
+		Speed:     4.25,
+		Course:    271.5,
+	}
+	if !reflect.DeepEqual(ext, expectedExt) {
+		t.Errorf("got %#v extension; expected %#v", ext, expectedExt)
+	}
+}
+
+func TestGarminTrackPointExtensionSpeedOnly(t *testing.T) {
+	f, err := os.Open("test/speed.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := NewDecoder(f).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []float64{3.1, 3.4}
+	points := doc.Tracks[0].Segments[0].Points
+	if len(points) != len(expected) {
+		t.Fatalf("got %d points; expected %d", len(points), len(expected))
+	}
+	for i, p := range points {
+		ext, err := ParseGarminTrackPointExtension(p.Extensions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectedExt := GarminTrackPointExtension{Speed: expected[i]}
+		if !reflect.DeepEqual(ext, expectedExt) {
+			t.Errorf("got %#v extension for point %d; expected %#v", ext, i, expectedExt)
+		}
+	}
+}
+
+func TestGarminPowerExtension(t *testing.T) {
+	tokens := extensionTokens(`<gpxpx:PowerInWatts xmlns:gpxpx="http://www.garmin.com/xmlschemas/PowerExtension/v1">250</gpxpx:PowerInWatts>`)
+
+	ext, err := ParseGarminPowerExtension(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := uint(250); ext.Power != expected {
+		t.Errorf("got %d power; expected %d", ext.Power, expected)
+	}
+
+	if _, err := ParseGarminPowerExtension(nil); err != ErrNoSuchExtension {
+		t.Errorf("expected ErrNoSuchExtension")
+	}
+}
+
+func TestParseExtensionValues(t *testing.T) {
+	tokens := extensionTokens(`<gpxtpx:TrackPointExtension xmlns:gpxtpx="http://www.garmin.com/xmlschemas/TrackPointExtension/v1"><gpxtpx:hr>140</gpxtpx:hr></gpxtpx:TrackPointExtension><acme:sensors xmlns:acme="https://example.com/acme"><acme:power>312</acme:power><acme:climate><acme:temperature>18.5</acme:temperature></acme:climate></acme:sensors>`)
+
+	values, err := ParseExtensionValues(tokens, "https://example.com/acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"power":       "312",
+		"temperature": "18.5",
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("got %v values; expected %v", values, expected)
+	}
+
+	if _, err := ParseExtensionValues(tokens, "https://example.com/other"); err != ErrNoSuchExtension {
+		t.Errorf("expected ErrNoSuchExtension")
+	}
+}
+
+// extensionTokens returns the tokens of s as they would be stored in a
+// point's extensions.
+func extensionTokens(s string) []xml.Token {
+	var tokens []xml.Token
+	dec := xml.NewDecoder(strings.NewReader(s))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return tokens
+		}
+		tokens = append(tokens, xml.CopyToken(tok))
+	}
+}
+
+func TestRouteInstruction(t *testing.T) {
+	tokens := extensionTokens(`<osmand:turn xmlns:osmand="https://osmand.net">TL</osmand:turn><osmand:offset xmlns:osmand="https://osmand.net">12</osmand:offset><osmand:instruction xmlns:osmand="https://osmand.net">Turn left onto Main Street</osmand:instruction>`)
+
+	ext, err := ParseRouteInstruction(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedExt := RouteInstruction{Text: "Turn left onto Main Street", Turn: "TL"}
+	if ext != expectedExt {
+		t.Errorf("got %#v instruction; expected %#v", ext, expectedExt)
+	}
+
+	if _, err := ParseRouteInstruction(extensionTokens(`<offset>12</offset>`)); err != ErrNoSuchExtension {
+		t.Errorf("expected ErrNoSuchExtension")
+	}
+}
+
+func TestGarminWaypointExtension(t *testing.T) {
+	tokens := extensionTokens(`<gpxx:WaypointExtension xmlns:gpxx="http://www.garmin.com/xmlschemas/GpxExtensions/v3">
+  <gpxx:Proximity>50</gpxx:Proximity>
+  <gpxx:DisplayMode>SymbolAndName</gpxx:DisplayMode>
+  <gpxx:Categories>
+    <gpxx:Category>Restaurants</gpxx:Category>
+    <gpxx:Category>Favorites</gpxx:Category>
+  </gpxx:Categories>
+  <gpxx:Address>
+    <gpxx:StreetAddress>Hauptmarkt 1</gpxx:StreetAddress>
+    <gpxx:City>Nürnberg</gpxx:City>
+    <gpxx:Country>Germany</gpxx:Country>
+    <gpxx:PostalCode>90403</gpxx:PostalCode>
+    <gpxx:Bogus>bogus</gpxx:Bogus>
+  </gpxx:Address>
+  <gpxx:PhoneNumber Category="Work">+49 911 1234</gpxx:PhoneNumber>
+</gpxx:WaypointExtension>`)
+
+	ext, err := ParseGarminWaypointExtension(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedExt := GarminWaypointExtension{
+		Proximity:   50,
+		DisplayMode: "SymbolAndName",
+		Categories:  []string{"Restaurants", "Favorites"},
+		Address: GarminAddress{
+			StreetAddress: []string{"Hauptmarkt 1"},
+			City:          "Nürnberg",
+			Country:       "Germany",
+			PostalCode:    "90403",
+		},
+		PhoneNumber: "+49 911 1234",
+	}
+	if !reflect.DeepEqual(ext, expectedExt) {
+		t.Errorf("got %#v extension; expected %#v", ext, expectedExt)
+	}
+
+	if _, err := ParseGarminWaypointExtension(nil); err != ErrNoSuchExtension {
+		t.Errorf("expected ErrNoSuchExtension")
+	}
+}
+
+func TestGarminRoutePointExtension(t *testing.T) {
+	tokens := extensionTokens(`<gpxx:RoutePointExtension xmlns:gpxx="http://www.garmin.com/xmlschemas/GpxExtensions/v3">
+  <gpxx:Subclass>000000000000FFFFFFFFFFFFFFFFFFFFFFFF</gpxx:Subclass>
+  <gpxx:rpt lat="49.3973693847656250" lon="11.1259574890136719">
+    <gpxx:Subclass>030075001A0A0C0E2B0400000000000000000000</gpxx:Subclass>
+  </gpxx:rpt>
+  <gpxx:rpt lat="49.3968467712402344" lon="11.1254367828369141"/>
+</gpxx:RoutePointExtension>`)
+
+	ext, err := ParseGarminRoutePointExtension(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedExt := GarminRoutePointExtension{
+		Subclass: "000000000000FFFFFFFFFFFFFFFFFFFFFFFF",
+		Points: []GarminRoutePoint{
+			{Latitude: 49.3973693847656250, Longitude: 11.1259574890136719, Subclass: "030075001A0A0C0E2B0400000000000000000000"},
+			{Latitude: 49.3968467712402344, Longitude: 11.1254367828369141},
+		},
+	}
+	if !reflect.DeepEqual(ext, expectedExt) {
+		t.Errorf("got %#v extension; expected %#v", ext, expectedExt)
+	}
+
+	if _, err := ParseGarminRoutePointExtension(nil); err != ErrNoSuchExtension {
+		t.Errorf("expected ErrNoSuchExtension")
+	}
+}
+
+func TestGarminCoursePoint(t *testing.T) {
+	f, err := os.Open("test/course.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	doc, err := NewDecoder(f).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	points := doc.Routes[0].Points
+	cp, err := ParseGarminCoursePoint(points[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := CoursePoint{
+		Name:      "Start of climb",
+		Symbol:    "Summit",
+		Type:      "Climb",
+		Latitude:  49.3973693847656250,
+		Longitude: 11.1259574890136719,
+		Subclass:  "000000000000FFFFFFFFFFFFFFFFFFFFFFFF",
+		Points: []GarminRoutePoint{
+			{Latitude: 49.3968467712402344, Longitude: 11.1254367828369141},
+		},
+	}
+	if !reflect.DeepEqual(cp, expected) {
+		t.Errorf("got %#v course point; expected %#v", cp, expected)
+	}
+
+	if _, err := ParseGarminCoursePoint(points[1]); err != ErrNoSuchExtension {
+		t.Errorf("expected ErrNoSuchExtension")
+	}
+	if points[1].Name != "Summit" {
+		t.Errorf("got %q name; expected %q", points[1].Name, "Summit")
+	}
+}
+
+func TestGpxdataLaps(t *testing.T) {
+	f, err := os.Open("test/gpxdata.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := NewDecoder(f).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	laps, err := ParseGpxdataLaps(doc.Tracks[0].Extensions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedLaps := []Lap{
+		{
+			Index:     1,
+			StartTime: time.Date(2015, 12, 13, 18, 35, 18, 0, time.UTC),
+			Duration:  300500 * time.Millisecond,
+			Distance:  1000,
+			Calories:  72,
+		},
+		{
+			Index:     2,
+			StartTime: time.Date(2015, 12, 13, 18, 40, 18, 500000000, time.UTC),
+			Duration:  280 * time.Second,
+			Distance:  1000,
+		},
+	}
+	if !reflect.DeepEqual(laps, expectedLaps) {
+		t.Errorf("got %#v laps; expected %#v", laps, expectedLaps)
+	}
+
+	if _, err := ParseGpxdataLaps(nil); err != ErrNoSuchExtension {
+		t.Errorf("expected ErrNoSuchExtension")
+	}
+}
@@ -0,0 +1,65 @@
+package gpx
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Shape is the shape of a generated track.
+type Shape int
+
+const (
+	ShapeLine       Shape = iota // A straight line along the initial bearing
+	ShapeCircle                  // A closed circle
+	ShapeRandomWalk              // A random walk
+)
+
+// GenerateOptions configures GenerateTrack.
+type GenerateOptions struct {
+	Shape   Shape
+	Points  int           // Number of points
+	Spacing float64       // Distance between consecutive points (meters)
+	Bearing float64       // Initial bearing (degrees)
+	Start   Point         // Position, elevation and time of the first point
+	Step    time.Duration // Time between consecutive points, if Start has a time
+
+	// The elevation follows a sine wave around Start.Elevation with the given
+	// amplitude (meters) and period (points). A zero period yields a flat
+	// profile.
+	ElevationAmplitude float64
+	ElevationPeriod    int
+
+	Seed int64 // Seed for ShapeRandomWalk
+}
+
+// GenerateTrack generates a synthetic single segment track as configured by
+// opts. It is meant for tests and benchmarks that need large tracks without
+// large fixtures.
+func GenerateTrack(opts GenerateOptions) Track {
+	rnd := rand.New(rand.NewSource(opts.Seed))
+	points := make([]Point, 0, opts.Points)
+	p := opts.Start
+	bearing := opts.Bearing
+	for i := 0; i < opts.Points; i++ {
+		if i > 0 {
+			switch opts.Shape {
+			case ShapeCircle:
+				bearing += 360.0 / float64(opts.Points-1)
+			case ShapeRandomWalk:
+				bearing = rnd.Float64() * 360
+			}
+			p.Latitude, p.Longitude = destination(p.Latitude, p.Longitude, bearing, opts.Spacing)
+			if !p.Time.IsZero() {
+				p.Time = p.Time.Add(opts.Step)
+			}
+		}
+		if opts.ElevationPeriod > 0 {
+			phase := 2 * math.Pi * float64(i) / float64(opts.ElevationPeriod)
+			p.Elevation = opts.Start.Elevation + opts.ElevationAmplitude*math.Sin(phase)
+			p.HasElevation = true
+		}
+		points = append(points, p)
+	}
+	return Track{Segments: []Segment{{Points: points}}}
+}
@@ -0,0 +1,45 @@
+package gpx
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestGenerateTrack(t *testing.T) {
+	start := Point{
+		Latitude:  49.3973693847656250,
+		Longitude: 11.1259574890136719,
+		Time:      time.Date(2015, 12, 13, 18, 35, 18, 0, time.UTC),
+	}
+
+	line := GenerateTrack(GenerateOptions{
+		Shape:   ShapeLine,
+		Points:  11,
+		Spacing: 100,
+		Start:   start,
+		Step:    time.Second,
+	})
+	if dist := line.Distance(); math.Abs(dist-1000) > 0.001 {
+		t.Errorf("got %f distance; expected 1000", dist)
+	}
+	if dur := line.Duration(); dur != 10*time.Second {
+		t.Errorf("got %s duration; expected 10s", dur)
+	}
+
+	circle := GenerateTrack(GenerateOptions{
+		Shape:   ShapeCircle,
+		Points:  101,
+		Spacing: 10,
+		Start:   start,
+	})
+	points := circle.Segments[0].Points
+	if dist := points[0].DistanceTo(points[len(points)-1]); dist > 1 {
+		t.Errorf("got %f distance between circle ends; expected a closed circle", dist)
+	}
+
+	walk := GenerateOptions{Shape: ShapeRandomWalk, Points: 50, Spacing: 10, Start: start, Seed: 42}
+	if a, b := GenerateTrack(walk).Distance(), GenerateTrack(walk).Distance(); a != b {
+		t.Errorf("random walks with the same seed differ: %f and %f", a, b)
+	}
+}
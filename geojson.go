@@ -0,0 +1,128 @@
+package gpx
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// A GeoJSONOption configures ToGeoJSON.
+type GeoJSONOption func(*geoJSONConfig)
+
+type geoJSONConfig struct {
+	coordTimes bool
+}
+
+// WithCoordTimes adds a coordTimes property to each feature holding the
+// RFC 3339 time of every coordinate, following the Mapbox convention, as
+// GeoJSON geometries can't carry per-vertex times. Alongside it, the
+// coordProps property holds the recorded elevation of every coordinate in
+// its elevation array. Both arrays are nested per segment like the
+// coordinates; points without a time get an empty string and points
+// without an elevation null.
+func WithCoordTimes() GeoJSONOption {
+	return func(c *geoJSONConfig) {
+		c.coordTimes = true
+	}
+}
+
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   geoJSONGeometry        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geoJSONGeometry struct {
+	Type        string        `json:"type"`
+	Coordinates [][][]float64 `json:"coordinates"`
+}
+
+// ToGeoJSON returns the document as a GeoJSON FeatureCollection holding a
+// MultiLineString feature per track, with a line string per segment. The
+// positions of a line string are [longitude, latitude, elevation] triples
+// when any of its points has an elevation, and [longitude, latitude] pairs
+// otherwise. In a line string of triples, points without an elevation take
+// that of the nearest preceding point with one, or of the first point with
+// one for points before it; WithCoordTimes tells them apart. The track name
+// and type are stored in the feature's properties.
+func (d Document) ToGeoJSON(opts ...GeoJSONOption) ([]byte, error) {
+	var c geoJSONConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	fc := geoJSONFeatureCollection{
+		Type:     "FeatureCollection",
+		Features: []geoJSONFeature{},
+	}
+	for _, t := range d.Tracks {
+		f := geoJSONFeature{
+			Type: "Feature",
+			Geometry: geoJSONGeometry{
+				Type:        "MultiLineString",
+				Coordinates: [][][]float64{},
+			},
+			Properties: map[string]interface{}{},
+		}
+		if t.Name != "" {
+			f.Properties["name"] = t.Name
+		}
+		if t.Type != "" {
+			f.Properties["type"] = t.Type
+		}
+
+		var times [][]string
+		var eles [][]*float64
+		for _, s := range t.Segments {
+			coords := make([][]float64, 0, len(s.Points))
+			segTimes := make([]string, 0, len(s.Points))
+			segEles := make([]*float64, 0, len(s.Points))
+			fill, threeD := firstElevation(s)
+			for _, p := range s.Points {
+				if p.hasElevation() {
+					ele := p.Elevation
+					fill = ele
+					segEles = append(segEles, &ele)
+				} else {
+					segEles = append(segEles, nil)
+				}
+				if threeD {
+					coords = append(coords, []float64{p.Longitude, p.Latitude, fill})
+				} else {
+					coords = append(coords, []float64{p.Longitude, p.Latitude})
+				}
+				if p.Time.IsZero() {
+					segTimes = append(segTimes, "")
+				} else {
+					segTimes = append(segTimes, p.Time.Format(time.RFC3339Nano))
+				}
+			}
+			f.Geometry.Coordinates = append(f.Geometry.Coordinates, coords)
+			times = append(times, segTimes)
+			eles = append(eles, segEles)
+		}
+		if c.coordTimes {
+			f.Properties["coordTimes"] = times
+			f.Properties["coordProps"] = map[string]interface{}{"elevation": eles}
+		}
+
+		fc.Features = append(fc.Features, f)
+	}
+
+	return json.Marshal(fc)
+}
+
+// firstElevation returns the elevation of the first point of s that has
+// one. The second return value is false when no point has one.
+func firstElevation(s Segment) (float64, bool) {
+	for _, p := range s.Points {
+		if p.hasElevation() {
+			return p.Elevation, true
+		}
+	}
+	return 0, false
+}
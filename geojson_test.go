@@ -0,0 +1,146 @@
+package gpx
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestToGeoJSON(t *testing.T) {
+	f, err := os.Open("test/test.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := NewDecoder(f).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fc struct {
+		Features []struct {
+			Geometry struct {
+				Coordinates [][][]float64
+			}
+			Properties struct {
+				Name       string
+				CoordTimes [][]string
+			}
+		}
+	}
+
+	data, err := doc.ToGeoJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &fc); err != nil {
+		t.Fatal(err)
+	}
+	if l := len(fc.Features); l != 1 {
+		t.Fatalf("got %d feature(s); expected 1", l)
+	}
+	feature := fc.Features[0]
+	if expected := "Running"; feature.Properties.Name != expected {
+		t.Errorf("got %q name; expected %q", feature.Properties.Name, expected)
+	}
+	if l := len(feature.Geometry.Coordinates[0]); l != 9 {
+		t.Errorf("got %d coordinate(s); expected 9", l)
+	}
+	if expected := []float64{11.1259574890136719, 49.3973693847656250, 346.874267578125}; !equalFloats(feature.Geometry.Coordinates[0][0], expected) {
+		t.Errorf("got %v coordinate; expected %v", feature.Geometry.Coordinates[0][0], expected)
+	}
+	if feature.Properties.CoordTimes != nil {
+		t.Error("expected no coordTimes by default")
+	}
+
+	data, err = doc.ToGeoJSON(WithCoordTimes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &fc); err != nil {
+		t.Fatal(err)
+	}
+	times := fc.Features[0].Properties.CoordTimes
+	if l := len(times[0]); l != 9 {
+		t.Fatalf("got %d coordinate time(s); expected 9", l)
+	}
+	if expected := "2015-12-13T18:35:18Z"; times[0][0] != expected {
+		t.Errorf("got %q time; expected %q", times[0][0], expected)
+	}
+}
+
+func TestToGeoJSONWithoutElevation(t *testing.T) {
+	doc := Document{Tracks: []Track{{Segments: []Segment{
+		{Points: []Point{
+			{Latitude: 49.39, Longitude: 11.12},
+			{Latitude: 49.40, Longitude: 11.13, HasElevation: true},
+			{Latitude: 49.41, Longitude: 11.14, Elevation: 350},
+			{Latitude: 49.42, Longitude: 11.15},
+		}},
+		{Points: []Point{
+			{Latitude: 49.43, Longitude: 11.16},
+		}},
+	}}}}
+
+	data, err := doc.ToGeoJSON(WithCoordTimes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fc struct {
+		Features []struct {
+			Geometry struct {
+				Coordinates [][][]float64
+			}
+			Properties struct {
+				CoordProps struct {
+					Elevation [][]*float64
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(data, &fc); err != nil {
+		t.Fatal(err)
+	}
+	feature := fc.Features[0]
+
+	expected := [][]float64{
+		{11.12, 49.39, 0},
+		{11.13, 49.40, 0},
+		{11.14, 49.41, 350},
+		{11.15, 49.42, 350},
+	}
+	for i, coord := range feature.Geometry.Coordinates[0] {
+		if !equalFloats(coord, expected[i]) {
+			t.Errorf("got %v position %d; expected %v", coord, i, expected[i])
+		}
+	}
+	if expected := []float64{11.16, 49.43}; !equalFloats(feature.Geometry.Coordinates[1][0], expected) {
+		t.Errorf("got %v position in a segment without elevation; expected %v", feature.Geometry.Coordinates[1][0], expected)
+	}
+
+	eles := feature.Properties.CoordProps.Elevation
+	if len(eles) != 2 || len(eles[0]) != 4 || len(eles[1]) != 1 {
+		t.Fatalf("got %v elevations; expected them nested per segment", eles)
+	}
+	for i, expected := range []*float64{nil, new(float64), &expected[2][2], nil} {
+		got := eles[0][i]
+		if (got == nil) != (expected == nil) || (got != nil && *got != *expected) {
+			t.Errorf("got %v elevation %d; expected %v", got, i, expected)
+		}
+	}
+	if eles[1][0] != nil {
+		t.Errorf("got %v elevation; expected null", *eles[1][0])
+	}
+}
+
+func equalFloats(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
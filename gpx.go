@@ -1,26 +1,55 @@
 package gpx
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"strconv"
+	"strings"
 	"time"
+	"unicode"
 )
 
-const nsGPX11 = "http://www.topografix.com/GPX/1/1"
+const (
+	nsGPX10 = "http://www.topografix.com/GPX/1/0"
+	nsGPX11 = "http://www.topografix.com/GPX/1/1"
+)
+
+// utf8BOM is the UTF-8 byte order mark some editors write at the start of
+// a file.
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
 
 var (
-	ErrBadRootTag = errors.New("gpx: root element must be <gpx>")
-	ErrGPX11Only  = errors.New("gpx: can only parse GPX 1.1 documents")
+	ErrBadRootTag         = errors.New("gpx: root element must be <gpx>")
+	ErrUnsupportedVersion = errors.New("gpx: can only parse GPX 1.0 and 1.1 documents")
+	ErrTruncated          = errors.New("gpx: document is truncated")
+
+	// ErrGPX11Only is the name ErrUnsupportedVersion had when only GPX 1.1
+	// documents could be parsed.
+	//
+	// Deprecated: Use ErrUnsupportedVersion.
+	ErrGPX11Only = ErrUnsupportedVersion
+
+	ErrTooManyPoints = errors.New("gpx: document has too many points")
+	ErrTooLarge      = errors.New("gpx: document is too large")
 )
 
-// Document represents a GPX document.
+// Document represents a GPX document. Decoded GPX 1.0 documents are
+// upgraded to GPX 1.1: Version is always "1.1" while OriginalVersion holds
+// the version the document declared.
 type Document struct {
-	Version  string
-	Metadata Metadata
-	Tracks   []Track
+	Version         string
+	OriginalVersion string
+	Metadata        Metadata
+	Waypoints       []Waypoint
+	Routes          []Route
+	Tracks          []Track
 }
 
 // DistanceInMeters returns the document's total distance in meters.
@@ -42,6 +71,59 @@ func (d Document) DistanceInMiles() float64 {
 	return d.DistanceInMeters() / 1609.0
 }
 
+// FirstPoint returns the document's first track point. The second return
+// value is false when the document has no track points.
+func (d Document) FirstPoint() (Point, bool) {
+	for _, t := range d.Tracks {
+		for _, s := range t.Segments {
+			if len(s.Points) > 0 {
+				return s.Points[0], true
+			}
+		}
+	}
+	return Point{}, false
+}
+
+// LastPoint returns the document's last track point. The second return
+// value is false when the document has no track points.
+func (d Document) LastPoint() (Point, bool) {
+	for i := len(d.Tracks) - 1; i >= 0; i-- {
+		segments := d.Tracks[i].Segments
+		for j := len(segments) - 1; j >= 0; j-- {
+			if points := segments[j].Points; len(points) > 0 {
+				return points[len(points)-1], true
+			}
+		}
+	}
+	return Point{}, false
+}
+
+// StraightLineDistance returns the distance in meters between the
+// document's first and last track point.
+func (d Document) StraightLineDistance() float64 {
+	first, ok := d.FirstPoint()
+	if !ok {
+		return 0
+	}
+	last, _ := d.LastPoint()
+	return first.DistanceTo(last)
+}
+
+// IsLoop reports whether the document's first and last track points lie
+// within toleranceMeters of each other. A document with fewer than two
+// points isn't a loop.
+func (d Document) IsLoop(toleranceMeters float64) bool {
+	var n int
+	for _, t := range d.Tracks {
+		for _, s := range t.Segments {
+			if n += len(s.Points); n >= 2 {
+				return d.StraightLineDistance() <= toleranceMeters
+			}
+		}
+	}
+	return false
+}
+
 // Duration returns the document's total duration.
 func (d Document) Duration() time.Duration {
 	var distance int64
@@ -67,6 +149,128 @@ func (d Document) End() time.Time {
 	return d.Tracks[len(d.Tracks)-1].End()
 }
 
+// BoundingBox returns the bounds of the document's track points, the union
+// of the bounding boxes of its tracks. Zero bounds are returned if there
+// are no points.
+func (d Document) BoundingBox() Bounds {
+	var b Bounds
+	var ok bool
+	for _, t := range d.Tracks {
+		if tb, tok := t.bounds(); tok {
+			if ok {
+				b = b.union(tb)
+			} else {
+				b = tb
+			}
+			ok = true
+		}
+	}
+	return b
+}
+
+// ComputeBounds returns the bounds of all of the document's points: its
+// track points, waypoints and route points. Unlike BoundingBox it includes
+// waypoints and routes, and its second return value is false when the
+// document has no points, telling an empty document apart from one at 0,0.
+func (d Document) ComputeBounds() (Bounds, bool) {
+	b, ok := Bounds{}, false
+	add := func(p Point) {
+		b.include(p, !ok)
+		ok = true
+	}
+	for _, t := range d.Tracks {
+		for _, s := range t.Segments {
+			for _, p := range s.Points {
+				add(p)
+			}
+		}
+	}
+	for _, w := range d.Waypoints {
+		add(Point{Latitude: w.Latitude, Longitude: w.Longitude})
+	}
+	for _, r := range d.Routes {
+		for _, p := range r.Points {
+			add(p)
+		}
+	}
+	return b, ok
+}
+
+// ExtentDiagonal returns the distance in meters between the southwest and
+// northeast corners of the document's bounding box, or zero if the document
+// has no points.
+func (d Document) ExtentDiagonal() float64 {
+	b := d.BoundingBox()
+	return haversine(b.MinLatitude, b.MinLongitude, b.MaxLatitude, b.MaxLongitude)
+}
+
+// AllPoints returns all track points of the document in document order.
+// The track and segment structure is lost in the flattened slice.
+func (d Document) AllPoints() []Point {
+	var points []Point
+	for _, t := range d.Tracks {
+		for _, s := range t.Segments {
+			points = append(points, s.Points...)
+		}
+	}
+	return points
+}
+
+// HasElevation reports whether any track point of the document has a
+// recorded elevation.
+func (d Document) HasElevation() bool {
+	return d.anyPoint(func(p *Point) bool { return p.hasElevation() })
+}
+
+// HasTime reports whether any track point of the document has a timestamp.
+func (d Document) HasTime() bool {
+	return d.anyPoint(func(p *Point) bool { return !p.Time.IsZero() })
+}
+
+// HasExtension reports whether any track point of the document has
+// extensions.
+func (d Document) HasExtension() bool {
+	return d.anyPoint(func(p *Point) bool { return len(p.Extensions) > 0 })
+}
+
+// anyPoint reports whether pred holds for any track point of the document,
+// stopping at the first one that does.
+func (d Document) anyPoint(pred func(p *Point) bool) bool {
+	for i := range d.Tracks {
+		for j := range d.Tracks[i].Segments {
+			points := d.Tracks[i].Segments[j].Points
+			for k := range points {
+				if pred(&points[k]) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// LatLons returns the latitudes and longitudes of all track points of the
+// document as parallel slices in document order.
+func (d Document) LatLons() ([]float64, []float64) {
+	var lats, lons []float64
+	for _, p := range d.AllPoints() {
+		lats = append(lats, p.Latitude)
+		lons = append(lons, p.Longitude)
+	}
+	return lats, lons
+}
+
+// FromPoints returns a GPX 1.1 document containing a single track with a
+// single segment holding points.
+func FromPoints(points []Point) Document {
+	return Document{
+		Version: "1.1",
+		Tracks: []Track{
+			{Segments: []Segment{{Points: points}}},
+		},
+	}
+}
+
 // Metadata provides additional information about a GPX document.
 type Metadata struct {
 	Name        string
@@ -80,6 +284,14 @@ type Metadata struct {
 	Extensions  []xml.Token
 }
 
+// KeywordList returns the metadata keywords split on white space and
+// commas, with empty keywords removed.
+func (m Metadata) KeywordList() []string {
+	return strings.FieldsFunc(m.Keywords, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+}
+
 // Person represents a person.
 type Person struct {
 	Name  string
@@ -115,11 +327,56 @@ type Bounds struct {
 	MaxLongitude float64
 }
 
-// Track represents a track.
+// AreaMeters returns the approximate ground area of the bounds in square
+// meters. The area is computed on a spherical earth, taking into account
+// that meridians converge towards the poles, so it is an approximation
+// that is most accurate away from the poles. Bounds crossing the
+// antimeridian aren't supported.
+func (b Bounds) AreaMeters() float64 {
+	rMinLat := b.MinLatitude * (math.Pi / 180.0)
+	rMaxLat := b.MaxLatitude * (math.Pi / 180.0)
+	dLon := (b.MaxLongitude - b.MinLongitude) * (math.Pi / 180.0)
+	return earthRadius * earthRadius * math.Abs(math.Sin(rMaxLat)-math.Sin(rMinLat)) * math.Abs(dLon)
+}
+
+// include extends the bounds to include p. If first is true the bounds are
+// reset to p.
+func (b *Bounds) include(p Point, first bool) {
+	if first {
+		*b = Bounds{p.Latitude, p.Longitude, p.Latitude, p.Longitude}
+		return
+	}
+	b.MinLatitude = math.Min(b.MinLatitude, p.Latitude)
+	b.MinLongitude = math.Min(b.MinLongitude, p.Longitude)
+	b.MaxLatitude = math.Max(b.MaxLatitude, p.Latitude)
+	b.MaxLongitude = math.Max(b.MaxLongitude, p.Longitude)
+}
+
+// union returns the bounds covering both b and b2.
+func (b Bounds) union(b2 Bounds) Bounds {
+	return Bounds{
+		MinLatitude:  math.Min(b.MinLatitude, b2.MinLatitude),
+		MinLongitude: math.Min(b.MinLongitude, b2.MinLongitude),
+		MaxLatitude:  math.Max(b.MaxLatitude, b2.MaxLatitude),
+		MaxLongitude: math.Max(b.MaxLongitude, b2.MaxLongitude),
+	}
+}
+
+// Route represents a route, an ordered list of points leading to a
+// destination.
+type Route struct {
+	Name   string
+	Points []Point
+}
+
+// Track represents a track. Extensions contains the raw XML tokens of the
+// track's extensions if it has any (excluding the <extensions> start and
+// end tag).
 type Track struct {
-	Name     string
-	Type     string
-	Segments []Segment
+	Name       string
+	Type       string
+	Segments   []Segment
+	Extensions []xml.Token
 }
 
 // Distance returns the track's total distance in meters.
@@ -156,6 +413,62 @@ func (t Track) End() time.Time {
 	return t.Segments[len(t.Segments)-1].End()
 }
 
+// TimeRange returns the earliest and latest timestamps of the track's
+// points, regardless of their order. The third return value is false when
+// no point has a timestamp.
+func (t Track) TimeRange() (start, end time.Time, ok bool) {
+	for _, s := range t.Segments {
+		for _, p := range s.Points {
+			if p.Time.IsZero() {
+				continue
+			}
+			if !ok || p.Time.Before(start) {
+				start = p.Time
+			}
+			if !ok || p.Time.After(end) {
+				end = p.Time
+			}
+			ok = true
+		}
+	}
+	return start, end, ok
+}
+
+// BoundingBox returns the bounds of the track's points, or zero bounds if
+// it has none.
+func (t Track) BoundingBox() Bounds {
+	b, _ := t.bounds()
+	return b
+}
+
+func (t Track) bounds() (b Bounds, ok bool) {
+	for _, s := range t.Segments {
+		if sb, sok := s.bounds(); sok {
+			if ok {
+				b = b.union(sb)
+			} else {
+				b = sb
+			}
+			ok = true
+		}
+	}
+	return b, ok
+}
+
+// Document returns a GPX 1.1 document holding only the track. The metadata
+// bounds are derived from the track's points and the metadata time is the
+// track's start time.
+func (t Track) Document() Document {
+	return Document{
+		Version: "1.1",
+		Metadata: Metadata{
+			Time:   t.Start(),
+			Bounds: t.BoundingBox(),
+		},
+		Tracks: []Track{t},
+	}
+}
+
 // Segments represents a track segment.
 type Segment struct {
 	Points []Point
@@ -181,6 +494,21 @@ func (s Segment) Duration() time.Duration {
 	return s.Points[ln-1].Time.Sub(s.Points[0].Time)
 }
 
+// DurationBetween returns the time elapsed between point i and point j. It
+// is negative when point j was recorded before point i, and zero when either
+// point lacks a timestamp. Indices outside of the segment return an error.
+func (s Segment) DurationBetween(i, j int) (time.Duration, error) {
+	for _, idx := range []int{i, j} {
+		if idx < 0 || idx >= len(s.Points) {
+			return 0, fmt.Errorf("gpx: point index %d out of range", idx)
+		}
+	}
+	if s.Points[i].Time.IsZero() || s.Points[j].Time.IsZero() {
+		return 0, nil
+	}
+	return s.Points[j].Time.Sub(s.Points[i].Time), nil
+}
+
 // Start returns the time of the first point.
 func (s Segment) Start() time.Time {
 	if len(s.Points) == 0 {
@@ -197,15 +525,88 @@ func (s Segment) End() time.Time {
 	return s.Points[len(s.Points)-1].Time
 }
 
-// Point represents a track point. Extensions contains the raw XML tokens
-// of the point's extensions if it has any (excluding the <extensions>
-// start and end tag).
+// BoundingBox returns the bounds of the segment's points, or zero bounds if
+// it has none.
+func (s Segment) BoundingBox() Bounds {
+	b, _ := s.bounds()
+	return b
+}
+
+func (s Segment) bounds() (b Bounds, ok bool) {
+	for i, p := range s.Points {
+		b.include(p, i == 0)
+	}
+	return b, len(s.Points) > 0
+}
+
+// CachedSegment wraps a segment and caches its length for callers querying
+// it repeatedly. Segment is a value type, so the cache can't notice changes
+// made to the points; callers modifying Points must call Invalidate.
+type CachedSegment struct {
+	Segment
+	length float64
+	valid  bool
+}
+
+// Length returns the segment's total distance in meters, computing it only
+// once until the cache is invalidated.
+func (c *CachedSegment) Length() float64 {
+	if !c.valid {
+		c.length = c.Distance()
+		c.valid = true
+	}
+	return c.length
+}
+
+// Invalidate clears the cached length.
+func (c *CachedSegment) Invalidate() {
+	c.valid = false
+}
+
+// Point represents a track or route point. HasElevation tells whether
+// Elevation was recorded, distinguishing sea level from missing data; a
+// non-zero Elevation counts as recorded even without the flag, so points
+// built in code needn't set it. The
+// dilutions of precision and Satellites are zero and Fix is empty when the
+// point doesn't record them. Extensions contains the raw XML tokens of the
+// point's extensions if it has any (excluding the <extensions> start and
+// end tag). The Raw fields hold the text the coordinates were decoded from
+// when the decoder preserves it.
 type Point struct {
-	Latitude   float64
-	Longitude  float64
-	Elevation  float64
-	Time       time.Time
-	Extensions []xml.Token
+	Latitude     float64
+	Longitude    float64
+	Elevation    float64
+	HasElevation bool
+	Time         time.Time
+	Name         string
+	Symbol       string  // Symbol name, e.g. "Summit"
+	Type         string  // Classification, e.g. the kind of a course point
+	HDOP         float64 // Horizontal dilution of precision
+	VDOP         float64 // Vertical dilution of precision
+	PDOP         float64 // Position dilution of precision
+	Satellites   uint    // Number of satellites used for the fix
+	Fix          string  // Type of fix: none, 2d, 3d, dgps or pps
+	Extensions   []xml.Token
+	RawLatitude  string
+	RawLongitude string
+	RawElevation string
+}
+
+// Waypoint represents a waypoint, a point of interest or named feature.
+// HasElevation tells whether Elevation was recorded, as for Point.
+// Extensions contains the raw XML tokens of the waypoint's extensions if it
+// has any (excluding the <extensions> start and end tag).
+type Waypoint struct {
+	Latitude     float64
+	Longitude    float64
+	Elevation    float64
+	HasElevation bool
+	Time         time.Time
+	Name         string
+	Description  string
+	Symbol       string
+	Type         string
+	Extensions   []xml.Token
 }
 
 // DistanceTo returns the distance in meters to point p2.
@@ -213,33 +614,254 @@ func (p Point) DistanceTo(p2 Point) float64 {
 	return haversine(p.Latitude, p.Longitude, p2.Latitude, p2.Longitude)
 }
 
-// Decoder decodes a GPX document from an input stream.
+// BearingTo returns the initial bearing in degrees [0, 360) to point p2,
+// where 0 is north and 90 is east.
+func (p Point) BearingTo(p2 Point) float64 {
+	return bearing(p.Latitude, p.Longitude, p2.Latitude, p2.Longitude)
+}
+
+// hasElevation reports whether the point has an elevation: whether it is
+// flagged with HasElevation or has a non-zero Elevation. A zero elevation
+// without the flag is taken as never set. Everything reading elevations
+// goes through it, so all of them agree on which points have one.
+func (p Point) hasElevation() bool {
+	return p.HasElevation || p.Elevation != 0
+}
+
+// hasElevation reports whether the waypoint has an elevation, following the
+// same rule as for points.
+func (w Waypoint) hasElevation() bool {
+	return w.HasElevation || w.Elevation != 0
+}
+
+// QuantizedKey returns a key of the form "lat,lon" with both coordinates
+// rounded to decimals decimal places. Points in the same grid cell share
+// the same key, which makes it suitable for spatial hash maps.
+func (p Point) QuantizedKey(decimals int) string {
+	return quantize(p.Latitude, decimals) + "," + quantize(p.Longitude, decimals)
+}
+
+// Decoder decodes a GPX document from an input stream. When PreserveRaw is
+// set, track and route points keep the text of their coordinates and
+// elevation in their Raw fields, so an Encoder can write them back
+// unchanged.
 type Decoder struct {
-	Strict bool
-	r      io.Reader
-	ts     tokenStream
+	Strict      bool
+	PreserveRaw bool
+	r           io.Reader
+	br          *bufio.Reader
+	xd          *xml.Decoder
+	ts          tokenStream
+
+	charsetReader  func(charset string, input io.Reader) (io.Reader, error)
+	skipExtensions bool
+	timeTruncate   time.Duration
+	pointFilter    func(Point) (Point, bool)
+	logger         func(msg string)
+	maxPoints      int
+	maxBytes       int64
+	points         int
+	warnings       []Warning
 }
 
-// NewDecoder creates a new decoder reading from r. The decoder
-// operates in strict mode.
-func NewDecoder(r io.Reader) *Decoder {
-	return &Decoder{
-		Strict: true,
-		r:      r,
+var errNotSelfClosing = errors.New("not a self-closing element")
+
+// A Warning describes an invalid value the decoder skipped in non-strict
+// mode. In strict mode it is returned wrapped in a *SyntaxError.
+type Warning struct {
+	Element   string // Name of the element holding the value
+	Attribute string // Name of the attribute holding the value, if any
+	Value     string // The invalid value
+	Err       error  // Why the value is invalid
+}
+
+func (w Warning) Error() string {
+	if w.Attribute != "" {
+		return fmt.Sprintf("gpx: invalid <%s> %s: %s", w.Element, w.Attribute, w.Err)
 	}
+	return fmt.Sprintf("gpx: invalid <%s>: %s", w.Element, w.Err)
+}
+
+// A SyntaxError is returned by a strict decoder for an invalid value. It
+// records where in the input the value was found.
+type SyntaxError struct {
+	Element string // Name of the element holding the value
+	Offset  int64  // Byte offset just past the start tag or element holding the value
+	Err     error  // The Warning describing the value
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s (at byte offset %d)", e.Err, e.Offset)
 }
 
-// Decode decodes a document.
+// Unwrap returns the Warning describing the invalid value.
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+// Warnings returns the problems the decoder tolerated in non-strict mode
+// while decoding the last document.
+func (d *Decoder) Warnings() []Warning {
+	return d.warnings
+}
+
+// NewDecoder creates a new decoder reading from r configured by opts. The
+// decoder operates in strict mode.
+func NewDecoder(r io.Reader, opts ...Option) *Decoder {
+	d := &Decoder{
+		Strict:        true,
+		r:             r,
+		charsetReader: defaultCharsetReader,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Reset makes the decoder read its next document from r, so a single
+// decoder can decode many documents without being reallocated. Strict,
+// PreserveRaw and the options the decoder was created with are kept; the
+// state of the previous document, including its warnings, is discarded.
+func (d *Decoder) Reset(r io.Reader) {
+	d.r = r
+	d.xd = nil
+	d.ts = tokenStream{}
+	d.points = 0
+	d.warnings = nil
+}
+
+// gzipMagic starts every gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// NewDecoderAuto creates a new decoder like NewDecoder, transparently
+// decompressing r if it is gzip-compressed, as .gpx.gz files are. An error
+// is returned if r can't be read or holds an invalid gzip header.
+func NewDecoderAuto(r io.Reader, opts ...Option) (*Decoder, error) {
+	br := bufio.NewReader(r)
+	b, err := br.Peek(len(gzipMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if !bytes.Equal(b, gzipMagic) {
+		return NewDecoder(br, opts...), nil
+	}
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, err
+	}
+	return NewDecoder(zr, opts...), nil
+}
+
+// Decode decodes a document. A leading UTF-8 byte order mark is skipped.
+// If the input ends before the document is complete, e.g. because a
+// download was interrupted, a decoder that isn't strict returns the part of
+// the document decoded so far along with ErrTruncated.
 func (d *Decoder) Decode() (doc Document, err error) {
-	dec := xml.NewDecoder(d.r)
-	d.ts = tokenStream{dec}
+	return d.DecodeContext(context.Background())
+}
+
+// DecodeContext decodes a document like Decode, stopping with ctx.Err() as
+// soon as ctx is canceled or its deadline expires.
+func (d *Decoder) DecodeContext(ctx context.Context) (doc Document, err error) {
+	err = d.decodeInto(ctx, &doc)
+	return doc, err
+}
+
+// DecodeInto decodes a document into doc, reusing the capacity of its
+// track, segment and point slices to avoid reallocating them when decoding
+// many documents in a row. The previous contents of doc are overwritten, so
+// callers must not retain references to them.
+func (d *Decoder) DecodeInto(doc *Document) error {
+	return d.decodeInto(context.Background(), doc)
+}
 
-	se, err := d.findGPX()
+func (d *Decoder) decodeInto(ctx context.Context, doc *Document) error {
+	*doc = Document{Tracks: doc.Tracks[:0]}
+
+	se, err := d.begin(ctx)
 	if err != nil {
-		return doc, err
+		return err
+	}
+
+	err = d.consumeGPX(se, doc)
+	if err != nil && !d.Strict && isTruncation(err) {
+		d.log(ErrTruncated.Error())
+		return ErrTruncated
+	}
+	return err
+}
+
+// begin starts reading the input and returns the <gpx> root element.
+// Reading fails with ctx.Err() once ctx is done.
+func (d *Decoder) begin(ctx context.Context) (xml.StartElement, error) {
+	r := d.r
+	if d.maxBytes > 0 {
+		r = &maxBytesReader{r: r, n: d.maxBytes}
+	}
+	dec := xml.NewDecoder(d.skipBOM(r))
+	dec.CharsetReader = d.charsetReader
+	d.xd = dec
+	d.ts = tokenStream{dec}
+	if ctx.Done() != nil {
+		d.ts = tokenStream{&contextTokener{tokener: dec, ctx: ctx}}
+	}
+	d.points = 0
+	d.warnings = nil
+	return d.findGPX()
+}
+
+// tolerate returns w wrapped in a *SyntaxError in strict mode. Otherwise it
+// records w and returns nil, so decoding continues.
+func (d *Decoder) tolerate(w Warning) error {
+	if d.Strict {
+		return &SyntaxError{Element: w.Element, Offset: d.xd.InputOffset(), Err: w}
 	}
+	d.warn(w)
+	return nil
+}
+
+// warn records w and logs it.
+func (d *Decoder) warn(w Warning) {
+	d.warnings = append(d.warnings, w)
+	d.log(w.Error())
+}
 
-	return d.consumeGPX(se)
+// log passes msg to the logger set with WithLogger, if any.
+func (d *Decoder) log(msg string) {
+	if d.logger != nil {
+		d.logger(msg)
+	}
+}
+
+// isTruncation reports whether err is caused by the input ending
+// prematurely.
+func isTruncation(err error) bool {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
+	}
+	serr, ok := err.(*xml.SyntaxError)
+	return ok && serr.Msg == "unexpected EOF"
+}
+
+// skipBOM returns a reader reading from r with a leading UTF-8 byte order
+// mark removed. A *bufio.Reader is used as is; other readers are wrapped in
+// a buffer the decoder reuses across documents. The caller's own reader is
+// never kept, so resetting the buffer can't affect it.
+func (d *Decoder) skipBOM(r io.Reader) io.Reader {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		if d.br == nil {
+			d.br = bufio.NewReader(r)
+		} else {
+			d.br.Reset(r)
+		}
+		br = d.br
+	}
+	if b, err := br.Peek(len(utf8BOM)); err == nil && bytes.Equal(b, utf8BOM) {
+		br.Discard(len(utf8BOM))
+	}
+	return br
 }
 
 func (d *Decoder) findGPX() (se xml.StartElement, err error) {
@@ -252,52 +874,129 @@ func (d *Decoder) findGPX() (se xml.StartElement, err error) {
 			if se.Name.Local != "gpx" {
 				return se, ErrBadRootTag
 			}
-			if se.Name.Space != nsGPX11 {
-				return se, ErrGPX11Only
+			if se.Name.Space != nsGPX10 && se.Name.Space != nsGPX11 {
+				return se, ErrUnsupportedVersion
 			}
 			return se, nil
 		}
 	}
 }
 
-func (d *Decoder) consumeGPX(se xml.StartElement) (doc Document, err error) {
+func (d *Decoder) consumeGPX(se xml.StartElement, doc *Document) error {
 	for _, a := range se.Attr {
 		switch a.Name.Local {
 		case "version":
 			doc.Version = a.Value
 		}
 	}
+	doc.OriginalVersion = doc.Version
+
+	gpx10 := se.Name.Space == nsGPX10
+	if gpx10 {
+		if doc.OriginalVersion == "" {
+			doc.OriginalVersion = "1.0"
+		}
+		doc.Version = "1.1"
+	}
 
 	for {
 		tok, err := d.ts.Token()
 		if err != nil {
-			return doc, err
+			return err
 		}
 		switch tok.(type) {
 		case xml.StartElement:
 			se := tok.(xml.StartElement)
 			switch se.Name.Local {
 			case "trk":
-				track, err := d.consumeTrack(se)
+				n := len(doc.Tracks)
+				if n < cap(doc.Tracks) {
+					doc.Tracks = doc.Tracks[:n+1]
+				} else {
+					doc.Tracks = append(doc.Tracks, Track{})
+				}
+				if err := d.consumeTrack(se, &doc.Tracks[n]); err != nil {
+					return err
+				}
+			case "rte":
+				route, err := d.consumeRoute(se)
+				if err != nil {
+					return err
+				}
+				doc.Routes = append(doc.Routes, route)
+			case "wpt":
+				waypoint, err := d.consumeWaypoint(se)
 				if err != nil {
-					return doc, err
+					return err
 				}
-				doc.Tracks = append(doc.Tracks, track)
+				doc.Waypoints = append(doc.Waypoints, waypoint)
 			case "metadata":
 				metadata, err := d.consumeMetadata(se)
 				if err != nil {
-					return doc, err
+					return err
 				}
 				doc.Metadata = metadata
 			default:
-				if err := d.ts.skipTag(); err != nil {
-					return doc, err
+				if gpx10 {
+					err = d.consumeGPX10Metadata(se, &doc.Metadata)
+				} else {
+					err = d.ts.skipTag()
+				}
+				if err != nil {
+					return err
 				}
 			}
 		case xml.EndElement:
-			return doc, nil
+			return nil
+		}
+	}
+}
+
+// consumeGPX10Metadata maps a top-level GPX 1.0 element onto its GPX 1.1
+// metadata counterpart. Unknown elements are skipped.
+func (d *Decoder) consumeGPX10Metadata(se xml.StartElement, metadata *Metadata) error {
+	switch se.Name.Local {
+	case "time":
+		t, err := d.consumeTime()
+		if err != nil {
+			return err
+		}
+		metadata.Time = t
+	case "bounds":
+		bounds, err := d.consumeBounds(se)
+		if err != nil {
+			return err
+		}
+		metadata.Bounds = bounds
+	case "name", "desc", "author", "email", "url", "urlname", "keywords":
+		s, err := d.ts.consumeString()
+		if err != nil {
+			return err
+		}
+		switch se.Name.Local {
+		case "name":
+			metadata.Name = s
+		case "desc":
+			metadata.Description = s
+		case "author":
+			metadata.Author.Name = s
+		case "email":
+			if i := strings.LastIndex(s, "@"); i >= 0 {
+				metadata.Author.Email = Email{ID: s[:i], Domain: s[i+1:]}
+			} else {
+				metadata.Author.Email = Email{ID: s}
+			}
+		case "url":
+			metadata.Link.Href = s
+		case "urlname":
+			metadata.Link.Text = s
+		case "keywords":
+			metadata.Keywords = s
 		}
+	default:
+		return d.ts.skipTag()
 	}
+	return nil
 }
 
 func (d *Decoder) consumeMetadata(se xml.StartElement) (metadata Metadata, err error) {
@@ -311,7 +1010,7 @@ func (d *Decoder) consumeMetadata(se xml.StartElement) (metadata Metadata, err e
 			se := tok.(xml.StartElement)
 			switch se.Name.Local {
 			case "time":
-				t, err := d.ts.consumeTime()
+				t, err := d.consumeTime()
 				if err != nil {
 					return metadata, err
 				}
@@ -358,6 +1057,18 @@ func (d *Decoder) consumeMetadata(se xml.StartElement) (metadata Metadata, err e
 					return metadata, err
 				}
 				metadata.Author = person
+			case "extensions":
+				if d.skipExtensions {
+					if err := d.ts.skipTag(); err != nil {
+						return metadata, err
+					}
+					continue
+				}
+				exts, err := d.consumeExtensions(se)
+				if err != nil {
+					return metadata, err
+				}
+				metadata.Extensions = exts
 			default:
 				if err := d.ts.skipTag(); err != nil {
 					return metadata, err
@@ -414,6 +1125,13 @@ func (d *Decoder) consumeCopyright(se xml.StartElement) (copyright Copyright, er
 		switch a.Name.Local {
 		case "author":
 			copyright.Author = a.Value
+		case "year":
+			year, err := parseYear(a.Value)
+			if err == nil {
+				copyright.Year = year
+			} else if err = d.tolerate(Warning{Element: "copyright", Attribute: "year", Value: a.Value, Err: err}); err != nil {
+				return copyright, err
+			}
 		}
 	}
 
@@ -427,11 +1145,16 @@ func (d *Decoder) consumeCopyright(se xml.StartElement) (copyright Copyright, er
 			se := tok.(xml.StartElement)
 			switch se.Name.Local {
 			case "year":
-				i, err := d.ts.consumeInt()
+				s, err := d.ts.consumeString()
 				if err != nil {
 					return copyright, err
 				}
-				copyright.Year = i
+				year, err := parseYear(s)
+				if err == nil {
+					copyright.Year = year
+				} else if err = d.tolerate(Warning{Element: "year", Value: s, Err: err}); err != nil {
+					return copyright, err
+				}
 			case "license":
 				s, err := d.ts.consumeString()
 				if err != nil {
@@ -449,6 +1172,15 @@ func (d *Decoder) consumeCopyright(se xml.StartElement) (copyright Copyright, er
 	}
 }
 
+// parseYear parses a copyright year, treating an empty value as zero.
+func parseYear(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(s)
+}
+
 func (d *Decoder) consumeBounds(se xml.StartElement) (bounds Bounds, err error) {
 	for _, a := range se.Attr {
 		switch a.Name.Local {
@@ -456,29 +1188,29 @@ func (d *Decoder) consumeBounds(se xml.StartElement) (bounds Bounds, err error)
 			minlat, err := strconv.ParseFloat(a.Value, 64)
 			if err == nil {
 				bounds.MinLatitude = minlat
-			} else if d.Strict {
-				return bounds, fmt.Errorf("gpx: invalid <bounds> minlat: %s", err)
+			} else if err = d.tolerate(Warning{Element: "bounds", Attribute: "minlat", Value: a.Value, Err: err}); err != nil {
+				return bounds, err
 			}
 		case "maxlat":
 			maxlat, err := strconv.ParseFloat(a.Value, 64)
 			if err == nil {
 				bounds.MaxLatitude = maxlat
-			} else if d.Strict {
-				return bounds, fmt.Errorf("gpx: invalid <bounds> maxlat: %s", err)
+			} else if err = d.tolerate(Warning{Element: "bounds", Attribute: "maxlat", Value: a.Value, Err: err}); err != nil {
+				return bounds, err
 			}
 		case "minlon":
 			minlon, err := strconv.ParseFloat(a.Value, 64)
 			if err == nil {
 				bounds.MinLongitude = minlon
-			} else if d.Strict {
-				return bounds, fmt.Errorf("gpx: invalid <bounds> minlon: %s", err)
+			} else if err = d.tolerate(Warning{Element: "bounds", Attribute: "minlon", Value: a.Value, Err: err}); err != nil {
+				return bounds, err
 			}
 		case "maxlon":
 			maxlon, err := strconv.ParseFloat(a.Value, 64)
 			if err == nil {
 				bounds.MaxLongitude = maxlon
-			} else if d.Strict {
-				return bounds, fmt.Errorf("gpx: invalid <bounds> maxlon: %s", err)
+			} else if err = d.tolerate(Warning{Element: "bounds", Attribute: "maxlon", Value: a.Value, Err: err}); err != nil {
+				return bounds, err
 			}
 		}
 	}
@@ -489,7 +1221,7 @@ func (d *Decoder) consumeBounds(se xml.StartElement) (bounds Bounds, err error)
 			return bounds, err
 		}
 		if _, ok := tok.(xml.EndElement); !ok {
-			return bounds, fmt.Errorf("gpx: <bounds> not a self-closing element")
+			return bounds, d.tolerate(Warning{Element: "bounds", Err: errNotSelfClosing})
 		}
 		return bounds, nil
 	}
@@ -501,6 +1233,7 @@ func (d *Decoder) consumeBounds(se xml.StartElement) (bounds Bounds, err error)
 		}
 		switch tok.(type) {
 		case xml.StartElement:
+			d.warn(Warning{Element: "bounds", Err: errNotSelfClosing})
 			if err := d.ts.skipTag(); err != nil {
 				return bounds, err
 			}
@@ -565,7 +1298,7 @@ func (d *Decoder) consumeEmail(se xml.StartElement) (email Email, err error) {
 			return email, err
 		}
 		if _, ok := tok.(xml.EndElement); !ok {
-			return email, fmt.Errorf("gpx: <email> not a self-closing element")
+			return email, d.tolerate(Warning{Element: "email", Err: errNotSelfClosing})
 		}
 		return email, nil
 	}
@@ -577,6 +1310,7 @@ func (d *Decoder) consumeEmail(se xml.StartElement) (email Email, err error) {
 		}
 		switch tok.(type) {
 		case xml.StartElement:
+			d.warn(Warning{Element: "email", Err: errNotSelfClosing})
 			if err := d.ts.skipTag(); err != nil {
 				return email, err
 			}
@@ -586,50 +1320,103 @@ func (d *Decoder) consumeEmail(se xml.StartElement) (email Email, err error) {
 	}
 }
 
-func (d *Decoder) consumeTrack(se xml.StartElement) (track Track, err error) {
+func (d *Decoder) consumeRoute(se xml.StartElement) (route Route, err error) {
 	for {
 		tok, err := d.ts.Token()
 		if err != nil {
-			return track, err
+			return route, err
 		}
 		switch tok.(type) {
 		case xml.StartElement:
 			se := tok.(xml.StartElement)
 			switch se.Name.Local {
-			case "trkseg":
-				seg, err := d.consumeSegment(se)
+			case "rtept":
+				point, err := d.consumePoint(se)
 				if err != nil {
-					return track, err
+					return route, err
+				}
+				route.Points = append(route.Points, point)
+			case "name":
+				s, err := d.ts.consumeString()
+				if err != nil {
+					return route, err
+				}
+				route.Name = s
+			default:
+				if err := d.ts.skipTag(); err != nil {
+					return route, err
+				}
+			}
+		case xml.EndElement:
+			return route, nil
+		}
+	}
+}
+
+func (d *Decoder) consumeTrack(se xml.StartElement, track *Track) error {
+	*track = Track{Segments: track.Segments[:0]}
+
+	for {
+		tok, err := d.ts.Token()
+		if err != nil {
+			return err
+		}
+		switch tok.(type) {
+		case xml.StartElement:
+			se := tok.(xml.StartElement)
+			switch se.Name.Local {
+			case "trkseg":
+				n := len(track.Segments)
+				if n < cap(track.Segments) {
+					track.Segments = track.Segments[:n+1]
+				} else {
+					track.Segments = append(track.Segments, Segment{})
+				}
+				if err := d.consumeSegment(se, &track.Segments[n]); err != nil {
+					return err
 				}
-				track.Segments = append(track.Segments, seg)
 			case "name":
 				name, err := d.ts.consumeString()
 				if err != nil {
-					return track, err
+					return err
 				}
 				track.Name = name
 			case "type":
 				trackType, err := d.ts.consumeString()
 				if err != nil {
-					return track, err
+					return err
 				}
 				track.Type = trackType
+			case "extensions":
+				if d.skipExtensions {
+					if err := d.ts.skipTag(); err != nil {
+						return err
+					}
+					continue
+				}
+				exts, err := d.consumeExtensions(se)
+				if err != nil {
+					return err
+				}
+				track.Extensions = exts
 			default:
 				if err := d.ts.skipTag(); err != nil {
-					return track, err
+					return err
 				}
 			}
 		case xml.EndElement:
-			return track, nil
+			return nil
 		}
 	}
 }
 
-func (d *Decoder) consumeSegment(se xml.StartElement) (seg Segment, err error) {
+func (d *Decoder) consumeSegment(se xml.StartElement, seg *Segment) error {
+	seg.Points = seg.Points[:0]
+
 	for {
 		tok, err := d.ts.Token()
 		if err != nil {
-			return seg, err
+			return err
 		}
 		switch tok.(type) {
 		case xml.StartElement:
@@ -638,36 +1425,154 @@ func (d *Decoder) consumeSegment(se xml.StartElement) (seg Segment, err error) {
 			case "trkpt":
 				point, err := d.consumePoint(se)
 				if err != nil {
-					return seg, err
+					return err
+				}
+				if d.pointFilter != nil {
+					var keep bool
+					if point, keep = d.pointFilter(point); !keep {
+						continue
+					}
 				}
 				seg.Points = append(seg.Points, point)
 			default:
 				if err := d.ts.skipTag(); err != nil {
-					return seg, err
+					return err
 				}
 			}
 		case xml.EndElement:
-			return seg, nil
+			return nil
 		}
 	}
 }
 
-func (d *Decoder) consumePoint(se xml.StartElement) (point Point, err error) {
+// consumeLatLon parses the lat and lon attributes of se.
+func (d *Decoder) consumeLatLon(se xml.StartElement) (lat, lon float64, err error) {
 	for _, a := range se.Attr {
 		switch a.Name.Local {
 		case "lat":
-			lat, err := strconv.ParseFloat(a.Value, 64)
+			f, err := strconv.ParseFloat(a.Value, 64)
 			if err == nil {
-				point.Latitude = lat
-			} else if d.Strict {
-				return point, fmt.Errorf("gpx: invalid <trkpt> lat: %s", err)
+				lat = f
+			} else if err = d.tolerate(Warning{Element: se.Name.Local, Attribute: "lat", Value: a.Value, Err: err}); err != nil {
+				return lat, lon, err
 			}
 		case "lon":
-			lon, err := strconv.ParseFloat(a.Value, 64)
+			f, err := strconv.ParseFloat(a.Value, 64)
 			if err == nil {
-				point.Longitude = lon
-			} else if d.Strict {
-				return point, fmt.Errorf("gpx: invalid <trkpt> lon: %s", err)
+				lon = f
+			} else if err = d.tolerate(Warning{Element: se.Name.Local, Attribute: "lon", Value: a.Value, Err: err}); err != nil {
+				return lat, lon, err
+			}
+		}
+	}
+	return lat, lon, nil
+}
+
+func (d *Decoder) consumeWaypoint(se xml.StartElement) (waypoint Waypoint, err error) {
+	if err := d.countPoint(); err != nil {
+		return waypoint, err
+	}
+
+	waypoint.Latitude, waypoint.Longitude, err = d.consumeLatLon(se)
+	if err != nil {
+		return waypoint, err
+	}
+
+	for {
+		tok, err := d.ts.Token()
+		if err != nil {
+			return waypoint, err
+		}
+		switch tok.(type) {
+		case xml.StartElement:
+			se := tok.(xml.StartElement)
+			switch se.Name.Local {
+			case "ele":
+				ele, ok, err := d.consumeFloat("ele")
+				if err != nil {
+					return waypoint, err
+				}
+				waypoint.Elevation = ele
+				waypoint.HasElevation = ok
+			case "time":
+				t, err := d.consumeTime()
+				if err != nil {
+					return waypoint, err
+				}
+				waypoint.Time = t
+			case "name":
+				s, err := d.ts.consumeString()
+				if err != nil {
+					return waypoint, err
+				}
+				waypoint.Name = s
+			case "desc":
+				s, err := d.ts.consumeString()
+				if err != nil {
+					return waypoint, err
+				}
+				waypoint.Description = s
+			case "sym":
+				s, err := d.ts.consumeString()
+				if err != nil {
+					return waypoint, err
+				}
+				waypoint.Symbol = s
+			case "type":
+				s, err := d.ts.consumeString()
+				if err != nil {
+					return waypoint, err
+				}
+				waypoint.Type = s
+			case "extensions":
+				if d.skipExtensions {
+					if err := d.ts.skipTag(); err != nil {
+						return waypoint, err
+					}
+					continue
+				}
+				exts, err := d.consumeExtensions(se)
+				if err != nil {
+					return waypoint, err
+				}
+				waypoint.Extensions = exts
+			default:
+				if err := d.ts.skipTag(); err != nil {
+					return waypoint, err
+				}
+			}
+		case xml.EndElement:
+			return waypoint, nil
+		}
+	}
+}
+
+// countPoint counts a waypoint, route point or track point, failing with
+// ErrTooManyPoints once the document holds more than allowed.
+func (d *Decoder) countPoint() error {
+	d.points++
+	if d.maxPoints > 0 && d.points > d.maxPoints {
+		return ErrTooManyPoints
+	}
+	return nil
+}
+
+func (d *Decoder) consumePoint(se xml.StartElement) (point Point, err error) {
+	if err := d.countPoint(); err != nil {
+		return point, err
+	}
+
+	point.Latitude, point.Longitude, err = d.consumeLatLon(se)
+	if err != nil {
+		return point, err
+	}
+	if d.PreserveRaw {
+		for _, a := range se.Attr {
+			switch a.Name.Local {
+			case "lat":
+				point.RawLatitude = a.Value
+			case "lon":
+				point.RawLongitude = a.Value
 			}
 		}
 	}
@@ -682,18 +1587,76 @@ func (d *Decoder) consumePoint(se xml.StartElement) (point Point, err error) {
 			se := tok.(xml.StartElement)
 			switch se.Name.Local {
 			case "ele":
-				ele, err := d.ts.consumeFloat()
+				ele, raw, ok, err := d.consumeRawFloat("ele")
 				if err != nil {
 					return point, err
 				}
 				point.Elevation = ele
+				point.HasElevation = ok
+				if d.PreserveRaw && ok {
+					point.RawElevation = raw
+				}
 			case "time":
-				t, err := d.ts.consumeTime()
+				t, err := d.consumeTime()
 				if err != nil {
 					return point, err
 				}
 				point.Time = t
+			case "name":
+				s, err := d.ts.consumeString()
+				if err != nil {
+					return point, err
+				}
+				point.Name = s
+			case "sym":
+				s, err := d.ts.consumeString()
+				if err != nil {
+					return point, err
+				}
+				point.Symbol = s
+			case "type":
+				s, err := d.ts.consumeString()
+				if err != nil {
+					return point, err
+				}
+				point.Type = s
+			case "hdop":
+				hdop, _, err := d.consumeFloat("hdop")
+				if err != nil {
+					return point, err
+				}
+				point.HDOP = hdop
+			case "vdop":
+				vdop, _, err := d.consumeFloat("vdop")
+				if err != nil {
+					return point, err
+				}
+				point.VDOP = vdop
+			case "pdop":
+				pdop, _, err := d.consumeFloat("pdop")
+				if err != nil {
+					return point, err
+				}
+				point.PDOP = pdop
+			case "fix":
+				fix, err := d.ts.consumeString()
+				if err != nil {
+					return point, err
+				}
+				point.Fix = fix
+			case "sat":
+				sat, err := d.consumeUint("sat")
+				if err != nil {
+					return point, err
+				}
+				point.Satellites = sat
 			case "extensions":
+				if d.skipExtensions {
+					if err := d.ts.skipTag(); err != nil {
+						return point, err
+					}
+					continue
+				}
 				exts, err := d.consumeExtensions(se)
 				if err != nil {
 					return point, err
@@ -710,6 +1673,68 @@ func (d *Decoder) consumePoint(se xml.StartElement) (point Point, err error) {
 	}
 }
 
+// consumeTime consumes a time, truncating it as configured. Invalid times
+// are tolerated as missing in non-strict mode.
+func (d *Decoder) consumeTime() (time.Time, error) {
+	s, err := d.ts.consumeString()
+	if err != nil {
+		return time.Time{}, err
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, d.tolerate(Warning{Element: "time", Value: s, Err: err})
+	}
+	if d.timeTruncate > 0 {
+		t = t.Truncate(d.timeTruncate)
+	}
+	return t, nil
+}
+
+// consumeFloat consumes the float content of element name. The second
+// return value is false when the element is empty or, in non-strict mode,
+// holds an invalid value.
+func (d *Decoder) consumeFloat(name string) (float64, bool, error) {
+	f, _, ok, err := d.consumeRawFloat(name)
+	return f, ok, err
+}
+
+// consumeRawFloat is like consumeFloat, but also returns the trimmed text
+// the float was parsed from.
+func (d *Decoder) consumeRawFloat(name string) (float64, string, bool, error) {
+	s, err := d.ts.consumeString()
+	if err != nil {
+		return 0, "", false, err
+	}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, "", false, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, "", false, d.tolerate(Warning{Element: name, Value: s, Err: err})
+	}
+	return f, s, true, nil
+}
+
+// consumeUint consumes the unsigned integer content of element name,
+// returning zero for an empty element or, in non-strict mode, an invalid
+// value.
+func (d *Decoder) consumeUint(name string) (uint, error) {
+	s, err := d.ts.consumeString()
+	if err != nil {
+		return 0, err
+	}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	i, err := strconv.ParseUint(s, 10, 0)
+	if err != nil {
+		return 0, d.tolerate(Warning{Element: name, Value: s, Err: err})
+	}
+	return uint(i), nil
+}
+
 func (d *Decoder) consumeExtensions(se xml.StartElement) (tokens []xml.Token, err error) {
 	lvl := 0
 
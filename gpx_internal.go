@@ -1,6 +1,9 @@
 package gpx
 
-import "math"
+import (
+	"math"
+	"strconv"
+)
 
 const earthRadius = 6371000
 
@@ -15,3 +18,72 @@ func haversine(lat1, lon1, lat2, lon2 float64) float64 {
 	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
 	return earthRadius * c
 }
+
+// destination returns the point reached when travelling distance meters
+// from lat/lon along the initial bearing (in degrees).
+func destination(lat, lon, bearing, distance float64) (float64, float64) {
+	rlat := lat * (math.Pi / 180.0)
+	rlon := lon * (math.Pi / 180.0)
+	rbearing := bearing * (math.Pi / 180.0)
+	d := distance / earthRadius
+	lat2 := math.Asin(math.Sin(rlat)*math.Cos(d) + math.Cos(rlat)*math.Sin(d)*math.Cos(rbearing))
+	lon2 := rlon + math.Atan2(math.Sin(rbearing)*math.Sin(d)*math.Cos(rlat), math.Cos(d)-math.Sin(rlat)*math.Sin(lat2))
+	return lat2 * (180.0 / math.Pi), lon2 * (180.0 / math.Pi)
+}
+
+// quantize formats f rounded to decimals decimal places. Values rounding to
+// zero are formatted without a sign.
+func quantize(f float64, decimals int) string {
+	scale := math.Pow(10, float64(decimals))
+	r := math.Round(f*scale) / scale
+	if r == 0 {
+		r = 0
+	}
+	return strconv.FormatFloat(r, 'f', decimals, 64)
+}
+
+// bearing returns the initial bearing in degrees [0, 360) from lat1/lon1 to
+// lat2/lon2.
+func bearing(lat1, lon1, lat2, lon2 float64) float64 {
+	rlat1 := lat1 * (math.Pi / 180.0)
+	rlat2 := lat2 * (math.Pi / 180.0)
+	dLon := (lon2 - lon1) * (math.Pi / 180.0)
+	y := math.Sin(dLon) * math.Cos(rlat2)
+	x := math.Cos(rlat1)*math.Sin(rlat2) - math.Sin(rlat1)*math.Cos(rlat2)*math.Cos(dLon)
+	return math.Mod(math.Atan2(y, x)*(180.0/math.Pi)+360, 360)
+}
+
+// crossTrack returns the distance in meters from lat3/lon3 to the great
+// circle through lat1/lon1 and lat2/lon2. When the two defining points
+// coincide it returns the distance to that point instead.
+func crossTrack(lat1, lon1, lat2, lon2, lat3, lon3 float64) float64 {
+	d13 := haversine(lat1, lon1, lat3, lon3)
+	if haversine(lat1, lon1, lat2, lon2) == 0 {
+		return d13
+	}
+	theta13 := bearing(lat1, lon1, lat3, lon3) * (math.Pi / 180.0)
+	theta12 := bearing(lat1, lon1, lat2, lon2) * (math.Pi / 180.0)
+	return math.Abs(math.Asin(math.Sin(d13/earthRadius)*math.Sin(theta13-theta12)) * earthRadius)
+}
+
+// segmentDistance returns the distance in meters from lat3/lon3 to the
+// closest point of the great circle arc from lat1/lon1 to lat2/lon2.
+func segmentDistance(lat1, lon1, lat2, lon2, lat3, lon3 float64) float64 {
+	d12 := haversine(lat1, lon1, lat2, lon2)
+	d13 := haversine(lat1, lon1, lat3, lon3)
+	if d12 == 0 {
+		return d13
+	}
+	theta13 := bearing(lat1, lon1, lat3, lon3) * (math.Pi / 180.0)
+	theta12 := bearing(lat1, lon1, lat2, lon2) * (math.Pi / 180.0)
+	if math.Cos(theta13-theta12) < 0 {
+		// The closest point lies before the start of the arc.
+		return d13
+	}
+	xt := crossTrack(lat1, lon1, lat2, lon2, lat3, lon3)
+	at := math.Acos(math.Min(1, math.Cos(d13/earthRadius)/math.Cos(xt/earthRadius))) * earthRadius
+	if at > d12 {
+		return haversine(lat2, lon2, lat3, lon3)
+	}
+	return xt
+}
@@ -1,8 +1,19 @@
 package gpx
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"math"
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -110,6 +121,12 @@ func testMetadata(t *testing.T, metadata Metadata) {
 	if expected := "run running forest sport sports"; metadata.Keywords != expected {
 		t.Errorf("expected keywords %q; got %q", expected, metadata.Keywords)
 	}
+	if expected := []string{"run", "running", "forest", "sport", "sports"}; !reflect.DeepEqual(metadata.KeywordList(), expected) {
+		t.Errorf("expected keyword list %q; got %q", expected, metadata.KeywordList())
+	}
+	if expected, list := []string{"hiking", "alps"}, (Metadata{Keywords: " hiking,alps ,, "}).KeywordList(); !reflect.DeepEqual(list, expected) {
+		t.Errorf("expected keyword list %q; got %q", expected, list)
+	}
 	if expected := time.Date(2015, 12, 13, 18, 35, 18, 0, time.UTC); !metadata.Time.Equal(expected) {
 		t.Errorf("expected time %q; got %q", expected, metadata.Time)
 	}
@@ -165,6 +182,7 @@ func testMetadataAuthor(t *testing.T, person Person) {
 	}
 
 	testMetadataAuthorEmail(t, person.Email)
+	testMetadataLink(t, person.Link)
 }
 
 func testMetadataAuthorEmail(t *testing.T, email Email) {
@@ -194,8 +212,1227 @@ func TestDecoderGPX10(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	_, err = NewDecoder(f).Decode()
-	if err != ErrGPX11Only {
-		t.Fatal("decoding should fail for GPX 1.0 documents")
+	doc, err := NewDecoder(f).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Version != "1.1" || doc.OriginalVersion != "1.0" {
+		t.Errorf("got version %q (originally %q); expected 1.1 (originally 1.0)", doc.Version, doc.OriginalVersion)
+	}
+	expected := Metadata{
+		Name:        "Morning run",
+		Description: "A short run through Nuremberg",
+		Author: Person{
+			Name:  "Jane Doe",
+			Email: Email{ID: "jane", Domain: "example.com"},
+		},
+		Link:     Link{Href: "https://example.com/jane", Text: "Jane's runs"},
+		Time:     time.Date(2015, 12, 13, 18, 35, 0, 0, time.UTC),
+		Keywords: "running, nuremberg",
+		Bounds: Bounds{
+			MinLatitude:  49.3968467712402344,
+			MinLongitude: 11.1254367828369141,
+			MaxLatitude:  49.3973693847656250,
+			MaxLongitude: 11.1259574890136719,
+		},
+	}
+	if !reflect.DeepEqual(doc.Metadata, expected) {
+		t.Errorf("got %+v metadata; expected %+v", doc.Metadata, expected)
+	}
+	if l := len(doc.AllPoints()); l != 2 {
+		t.Errorf("got %d point(s); expected 2", l)
+	}
+	if expected := 1.2; doc.Tracks[0].Segments[0].Points[0].HDOP != expected {
+		t.Errorf("got %v hdop; expected %v", doc.Tracks[0].Segments[0].Points[0].HDOP, expected)
+	}
+}
+
+func TestDecoderUnknownVersion(t *testing.T) {
+	const data = `<gpx version="2.0" xmlns="http://www.topografix.com/GPX/2/0"></gpx>`
+
+	_, err := NewDecoder(strings.NewReader(data)).Decode()
+	if err != ErrUnsupportedVersion {
+		t.Errorf("got %v error; expected %v", err, ErrUnsupportedVersion)
+	}
+}
+
+func TestDecoderOriginalVersion(t *testing.T) {
+	f, err := os.Open("test/test.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := NewDecoder(f).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.OriginalVersion != "1.1" {
+		t.Errorf("got original version %q; expected 1.1", doc.OriginalVersion)
+	}
+}
+
+func TestAllPointsFromPoints(t *testing.T) {
+	f, err := os.Open("test/test.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := NewDecoder(f).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	points := doc.AllPoints()
+	if l := len(points); l != 9 {
+		t.Fatalf("got %d point(s); expected 9", l)
+	}
+
+	lats, lons := doc.LatLons()
+	if len(lats) != len(points) || len(lons) != len(points) {
+		t.Fatalf("got %d latitude(s) and %d longitude(s); expected %d", len(lats), len(lons), len(points))
+	}
+	for i, p := range points {
+		if lats[i] != p.Latitude || lons[i] != p.Longitude {
+			t.Errorf("got %v,%v at index %d; expected %v,%v", lats[i], lons[i], i, p.Latitude, p.Longitude)
+		}
+	}
+
+	flat := FromPoints(points)
+	if l := len(flat.Tracks); l != 1 {
+		t.Errorf("got %d track(s); expected 1", l)
+	}
+	if dist, expected := flat.DistanceInMeters(), doc.DistanceInMeters(); dist != expected {
+		t.Errorf("got %f distance; expected %f", dist, expected)
+	}
+}
+
+func TestDecoderBOM(t *testing.T) {
+	f, err := os.Open("test/bom.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := NewDecoder(f).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := len(doc.Tracks); l != 1 {
+		t.Fatalf("got %d track(s); expected 1", l)
+	}
+	if expected := "BOM"; doc.Tracks[0].Name != expected {
+		t.Errorf("got %q name; expected %q", doc.Tracks[0].Name, expected)
+	}
+}
+
+func TestDecoderLatin1(t *testing.T) {
+	f, err := os.Open("test/latin1.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := NewDecoder(f).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "Wanderung über den Brünig"; doc.Tracks[0].Name != expected {
+		t.Errorf("got %q name; expected %q", doc.Tracks[0].Name, expected)
+	}
+}
+
+func TestDecoderCharsetReader(t *testing.T) {
+	f, err := os.Open("test/latin1.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errCharset := errors.New("charset")
+	charsetReader := func(charset string, input io.Reader) (io.Reader, error) {
+		return nil, errCharset
+	}
+	if _, err := NewDecoder(f, WithCharsetReader(charsetReader)).Decode(); err == nil {
+		t.Error("expected decoding to fail with a custom charset reader")
+	}
+}
+
+func TestPointQuantizedKey(t *testing.T) {
+	testCases := []struct {
+		point    Point
+		decimals int
+		key      string
+	}{
+		{Point{Latitude: 49.39736, Longitude: 11.12595}, 3, "49.397,11.126"},
+		{Point{Latitude: -33.86785, Longitude: -151.20732}, 2, "-33.87,-151.21"},
+		{Point{Latitude: -0.0004, Longitude: 0.0004}, 3, "0.000,0.000"},
+		{Point{Latitude: -12.5, Longitude: 12.5}, 0, "-13,13"},
+	}
+
+	for i, testCase := range testCases {
+		if key := testCase.point.QuantizedKey(testCase.decimals); key != testCase.key {
+			t.Errorf("test case %d: got %q key; expected %q", i, key, testCase.key)
+		}
+	}
+}
+
+func TestStraightLineDistance(t *testing.T) {
+	doc := FromPoints([]Point{
+		{Latitude: 49.3973693847656250, Longitude: 11.1259574890136719},
+		{Latitude: 49.4017448425292969, Longitude: 11.1280641555786133},
+		{Latitude: 49.3978729248046875, Longitude: 11.1260004043579102},
+	})
+
+	expected := doc.Tracks[0].Segments[0].Points[0].DistanceTo(doc.Tracks[0].Segments[0].Points[2])
+	if dist := doc.StraightLineDistance(); dist != expected {
+		t.Errorf("got %f straight line distance; expected %f", dist, expected)
+	}
+	if dist := doc.StraightLineDistance(); dist >= doc.DistanceInMeters() {
+		t.Errorf("got %f straight line distance; expected less than %f", dist, doc.DistanceInMeters())
+	}
+}
+
+func TestDecodeInto(t *testing.T) {
+	data, err := ioutil.ReadFile("test/test.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := NewDecoder(bytes.NewReader(data)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc Document
+	for i := 0; i < 2; i++ {
+		if err := NewDecoder(bytes.NewReader(data)).DecodeInto(&doc); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(doc, expected) {
+			t.Errorf("run %d: DecodeInto differs from Decode", i)
+		}
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	data, err := ioutil.ReadFile("test/test.gpx")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewDecoder(bytes.NewReader(data)).Decode(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeInto(b *testing.B) {
+	data, err := ioutil.ReadFile("test/test.gpx")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	var doc Document
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := NewDecoder(bytes.NewReader(data)).DecodeInto(&doc); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestDecoderWithoutExtensions(t *testing.T) {
+	f, err := os.Open("test/test.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := NewDecoder(f, WithoutExtensions()).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range doc.AllPoints() {
+		if p.Extensions != nil {
+			t.Fatalf("got %d extension token(s); expected none", len(p.Extensions))
+		}
+	}
+	if l := len(doc.AllPoints()); l != 9 {
+		t.Errorf("got %d point(s); expected 9", l)
+	}
+}
+
+// heartRateGPX returns a document with n points carrying heart rate
+// extensions.
+func heartRateGPX(n int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`<gpx version="1.1" xmlns="http://www.topografix.com/GPX/1/1" xmlns:gpxtpx="http://www.garmin.com/xmlschemas/TrackPointExtension/v1"><trk><trkseg>`)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&buf, `<trkpt lat="49.%d" lon="11.%d"><ele>346</ele><time>2015-12-13T18:35:18Z</time><extensions><gpxtpx:TrackPointExtension><gpxtpx:hr>%d</gpxtpx:hr><gpxtpx:cad>81</gpxtpx:cad></gpxtpx:TrackPointExtension></extensions></trkpt>`, i, i, 100+i%80)
+	}
+	buf.WriteString(`</trkseg></trk></gpx>`)
+	return buf.Bytes()
+}
+
+func BenchmarkDecodeExtensions(b *testing.B) {
+	data := heartRateGPX(1000)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewDecoder(bytes.NewReader(data)).Decode(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeWithoutExtensions(b *testing.B) {
+	data := heartRateGPX(1000)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewDecoder(bytes.NewReader(data), WithoutExtensions()).Decode(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestDecoderBounds(t *testing.T) {
+	const partial = `<gpx version="1.1" xmlns="http://www.topografix.com/GPX/1/1"><metadata><bounds minlat="49.5" maxlon="11.25"/></metadata></gpx>`
+	doc, err := NewDecoder(strings.NewReader(partial)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (Bounds{MinLatitude: 49.5, MaxLongitude: 11.25}); doc.Metadata.Bounds != expected {
+		t.Errorf("got %+v bounds; expected %+v", doc.Metadata.Bounds, expected)
+	}
+
+	const invalid = `<gpx version="1.1" xmlns="http://www.topografix.com/GPX/1/1"><metadata><bounds minlat="north" maxlat="49.75"/></metadata></gpx>`
+	if _, err := NewDecoder(strings.NewReader(invalid)).Decode(); err == nil {
+		t.Error("expected strict decoding to fail on an invalid bounds attribute")
+	}
+
+	d := NewDecoder(strings.NewReader(invalid))
+	d.Strict = false
+	doc, err = d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (Bounds{MaxLatitude: 49.75}); doc.Metadata.Bounds != expected {
+		t.Errorf("got %+v bounds; expected %+v", doc.Metadata.Bounds, expected)
+	}
+}
+
+func TestDecoderWithTimeTruncate(t *testing.T) {
+	const data = `<gpx version="1.1" xmlns="http://www.topografix.com/GPX/1/1"><metadata><time>2015-12-13T18:35:18.750Z</time></metadata><trk><trkseg><trkpt lat="49.39" lon="11.12"><time>2015-12-13T18:35:19.250Z</time></trkpt></trkseg></trk></gpx>`
+
+	doc, err := NewDecoder(strings.NewReader(data), WithTimeTruncate(time.Second)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := time.Date(2015, 12, 13, 18, 35, 18, 0, time.UTC); !doc.Metadata.Time.Equal(expected) {
+		t.Errorf("got %v metadata time; expected %v", doc.Metadata.Time, expected)
+	}
+	if expected := time.Date(2015, 12, 13, 18, 35, 19, 0, time.UTC); !doc.Start().Equal(expected) {
+		t.Errorf("got %v point time; expected %v", doc.Start(), expected)
+	}
+
+	doc, err = NewDecoder(strings.NewReader(data)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := time.Date(2015, 12, 13, 18, 35, 19, 250000000, time.UTC); !doc.Start().Equal(expected) {
+		t.Errorf("got %v point time; expected %v", doc.Start(), expected)
+	}
+}
+
+func TestPointBearingTo(t *testing.T) {
+	origin := Point{Latitude: 0, Longitude: 0}
+	testCases := []struct {
+		point   Point
+		bearing float64
+	}{
+		{Point{Latitude: 1, Longitude: 0}, 0},
+		{Point{Latitude: 0, Longitude: 1}, 90},
+		{Point{Latitude: -1, Longitude: 0}, 180},
+		{Point{Latitude: 0, Longitude: -1}, 270},
+	}
+
+	for i, testCase := range testCases {
+		if b := origin.BearingTo(testCase.point); math.Abs(b-testCase.bearing) > 1e-9 {
+			t.Errorf("test case %d: got %f bearing; expected %f", i, b, testCase.bearing)
+		}
+	}
+}
+
+func TestDecoderSelfClosingPoints(t *testing.T) {
+	f, err := os.Open("test/self_closing.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := NewDecoder(f).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	points := doc.AllPoints()
+	if l := len(points); l != 4 {
+		t.Fatalf("got %d point(s); expected 4", l)
+	}
+	expected := Point{Latitude: 49.3973693847656250, Longitude: 11.1259574890136719}
+	if !reflect.DeepEqual(points[0], expected) {
+		t.Errorf("got %+v point; expected %+v", points[0], expected)
+	}
+	if expected := 349.4727478027344; points[2].Elevation != expected {
+		t.Errorf("got %v elevation; expected %v", points[2].Elevation, expected)
+	}
+	if expected := 11.1256294250488281; points[3].Longitude != expected {
+		t.Errorf("got %v longitude; expected %v", points[3].Longitude, expected)
+	}
+}
+
+func TestDocumentHasData(t *testing.T) {
+	f, err := os.Open("test/test.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := NewDecoder(f).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !doc.HasElevation() || !doc.HasTime() || !doc.HasExtension() {
+		t.Errorf("got elevation %t, time %t and extension %t; expected all", doc.HasElevation(), doc.HasTime(), doc.HasExtension())
+	}
+
+	doc = FromPoints([]Point{{Latitude: 49.39, Longitude: 11.12}})
+	if doc.HasElevation() || doc.HasTime() || doc.HasExtension() {
+		t.Errorf("got elevation %t, time %t and extension %t; expected none", doc.HasElevation(), doc.HasTime(), doc.HasExtension())
+	}
+
+	doc.Tracks[0].Segments[0].Points[0].HasElevation = true
+	if !doc.HasElevation() {
+		t.Error("expected a point at sea level to have elevation")
+	}
+
+	doc = FromPoints([]Point{{Latitude: 49.39, Longitude: 11.12, Elevation: 346}})
+	if !doc.HasElevation() {
+		t.Error("expected a point with a non-zero elevation to have elevation without the flag")
+	}
+}
+
+func TestBoundsAreaMeters(t *testing.T) {
+	// A 0.01° square at the equator is about 1.11 km wide and high.
+	equator := Bounds{MinLatitude: 0, MinLongitude: 0, MaxLatitude: 0.01, MaxLongitude: 0.01}
+	side := earthRadius * 0.01 * math.Pi / 180
+	if area := equator.AreaMeters(); math.Abs(area-side*side)/(side*side) > 1e-4 {
+		t.Errorf("got %f area; expected %f", area, side*side)
+	}
+
+	// At 60° latitude meridians are half as far apart.
+	north := Bounds{MinLatitude: 60, MinLongitude: 0, MaxLatitude: 60.01, MaxLongitude: 0.01}
+	if ratio := north.AreaMeters() / equator.AreaMeters(); math.Abs(ratio-0.5) > 1e-3 {
+		t.Errorf("got %f area ratio; expected 0.5", ratio)
+	}
+}
+
+func TestIsLoop(t *testing.T) {
+	start := Point{Latitude: 49.3973693847656250, Longitude: 11.1259574890136719}
+	circle := GenerateTrack(GenerateOptions{Shape: ShapeCircle, Points: 41, Spacing: 25, Start: start})
+	line := GenerateTrack(GenerateOptions{Shape: ShapeLine, Points: 41, Spacing: 25, Start: start})
+
+	if !(Document{Tracks: []Track{circle}}).IsLoop(10) {
+		t.Error("expected circle to be a loop")
+	}
+	if (Document{Tracks: []Track{line}}).IsLoop(10) {
+		t.Error("expected line not to be a loop")
+	}
+	if FromPoints([]Point{start}).IsLoop(10) {
+		t.Error("expected single point not to be a loop")
+	}
+	split := Document{Tracks: []Track{{Segments: []Segment{{Points: []Point{start}}}}, {Segments: []Segment{{Points: []Point{start}}}}}}
+	if !split.IsLoop(10) {
+		t.Error("expected points in separate tracks to be a loop")
+	}
+}
+
+func TestDecoderPrefixedRoot(t *testing.T) {
+	f, err := os.Open("test/prefixed.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := NewDecoder(f).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Version != "1.1" {
+		t.Errorf("got wrong version %q", doc.Version)
+	}
+	if expected := "Prefixed"; doc.Metadata.Name != expected {
+		t.Errorf("got %q name; expected %q", doc.Metadata.Name, expected)
+	}
+	if l := len(doc.AllPoints()); l != 2 {
+		t.Errorf("got %d point(s); expected 2", l)
+	}
+	if expected := 346.874267578125; doc.Tracks[0].Segments[0].Points[0].Elevation != expected {
+		t.Errorf("got %v elevation; expected %v", doc.Tracks[0].Segments[0].Points[0].Elevation, expected)
+	}
+}
+
+func TestCachedSegmentLength(t *testing.T) {
+	track := GenerateTrack(GenerateOptions{Points: 11, Spacing: 100, Start: Point{Latitude: 49.4, Longitude: 11.1}})
+	seg := CachedSegment{Segment: track.Segments[0]}
+
+	if l := seg.Length(); math.Abs(l-1000) > 0.001 {
+		t.Errorf("got %f length; expected 1000", l)
+	}
+
+	seg.Points = seg.Points[:6]
+	if l := seg.Length(); math.Abs(l-1000) > 0.001 {
+		t.Errorf("got %f cached length; expected 1000", l)
+	}
+	seg.Invalidate()
+	if l := seg.Length(); math.Abs(l-500) > 0.001 {
+		t.Errorf("got %f length after invalidation; expected 500", l)
+	}
+}
+
+func TestTrackDocument(t *testing.T) {
+	f, err := os.Open("test/test.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := NewDecoder(f).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trackDoc := doc.Tracks[0].Document()
+	if trackDoc.Version != "1.1" {
+		t.Errorf("got wrong version %q", trackDoc.Version)
+	}
+	if !trackDoc.Metadata.Time.Equal(doc.Start()) {
+		t.Errorf("got %v metadata time; expected %v", trackDoc.Metadata.Time, doc.Start())
+	}
+	b := trackDoc.Metadata.Bounds
+	for _, p := range doc.AllPoints() {
+		if !b.contains(p.Latitude, p.Longitude) {
+			t.Errorf("point %v,%v lies outside %+v", p.Latitude, p.Longitude, b)
+		}
+	}
+	if b.MinLatitude == b.MaxLatitude || b.MinLongitude == b.MaxLongitude {
+		t.Errorf("got degenerate bounds %+v", b)
+	}
+}
+
+func TestBoundingBox(t *testing.T) {
+	doc := Document{Tracks: []Track{
+		{Segments: []Segment{
+			{Points: []Point{{Latitude: 49.1, Longitude: 11.1}, {Latitude: 49.3, Longitude: 11.2}}},
+			{},
+		}},
+		{Segments: []Segment{
+			{Points: []Point{{Latitude: 49.2, Longitude: 10.9}}},
+		}},
+		{},
+	}}
+
+	if expected := (Bounds{MinLatitude: 49.1, MinLongitude: 11.1, MaxLatitude: 49.3, MaxLongitude: 11.2}); doc.Tracks[0].BoundingBox() != expected {
+		t.Errorf("got %+v track bounds; expected %+v", doc.Tracks[0].BoundingBox(), expected)
+	}
+	if expected := (Bounds{MinLatitude: 49.2, MinLongitude: 10.9, MaxLatitude: 49.2, MaxLongitude: 10.9}); doc.Tracks[1].Segments[0].BoundingBox() != expected {
+		t.Errorf("got %+v segment bounds; expected %+v", doc.Tracks[1].Segments[0].BoundingBox(), expected)
+	}
+	if expected := (Bounds{MinLatitude: 49.1, MinLongitude: 10.9, MaxLatitude: 49.3, MaxLongitude: 11.2}); doc.BoundingBox() != expected {
+		t.Errorf("got %+v document bounds; expected %+v", doc.BoundingBox(), expected)
+	}
+	if b := (Document{}).BoundingBox(); b != (Bounds{}) {
+		t.Errorf("got %+v bounds for an empty document; expected zero bounds", b)
+	}
+}
+
+func TestComputeBounds(t *testing.T) {
+	doc := decodeFile(t, "test/test.gpx")
+
+	b, ok := doc.ComputeBounds()
+	if !ok {
+		t.Fatal("expected bounds")
+	}
+	expected := doc.Metadata.Bounds
+	for _, c := range [][2]float64{
+		{b.MinLatitude, expected.MinLatitude},
+		{b.MinLongitude, expected.MinLongitude},
+		{b.MaxLatitude, expected.MaxLatitude},
+		{b.MaxLongitude, expected.MaxLongitude},
+	} {
+		if math.Abs(c[0]-c[1]) > 1e-6 {
+			t.Errorf("got %+v bounds; expected %+v", b, expected)
+			break
+		}
+	}
+
+	doc = Document{
+		Waypoints: []Waypoint{{Latitude: 51.1, Longitude: 3.6}},
+		Routes:    []Route{{Points: []Point{{Latitude: 50.9, Longitude: 3.8}}}},
+	}
+	if b, ok := doc.ComputeBounds(); !ok || b != (Bounds{MinLatitude: 50.9, MinLongitude: 3.6, MaxLatitude: 51.1, MaxLongitude: 3.8}) {
+		t.Errorf("got %+v, %v bounds for waypoints and routes", b, ok)
+	}
+	if _, ok := (Document{}).ComputeBounds(); ok {
+		t.Error("expected no bounds for an empty document")
+	}
+}
+
+func TestDecoderTruncated(t *testing.T) {
+	data, err := ioutil.ReadFile("test/test.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	truncated := data[:bytes.Index(data, []byte("2015-12-13T19:14:37"))]
+
+	if _, err := NewDecoder(bytes.NewReader(truncated)).Decode(); err == nil || err == ErrTruncated {
+		t.Errorf("expected strict decoding to fail with a syntax error; got %v", err)
+	}
+
+	d := NewDecoder(bytes.NewReader(truncated))
+	d.Strict = false
+	doc, err := d.Decode()
+	if err != ErrTruncated {
+		t.Fatalf("expected ErrTruncated; got %v", err)
+	}
+	if l := len(doc.AllPoints()); l != 8 {
+		t.Errorf("got %d point(s); expected 8", l)
+	}
+	if expected := "Run"; doc.Metadata.Name != expected {
+		t.Errorf("got %q name; expected %q", doc.Metadata.Name, expected)
+	}
+}
+
+func TestFirstLastPoint(t *testing.T) {
+	doc := Document{Tracks: []Track{
+		{},
+		{Segments: []Segment{{}, {Points: []Point{{Latitude: 1}, {Latitude: 2}}}}},
+		{Segments: []Segment{{Points: []Point{{Latitude: 3}}}, {}}},
+		{},
+	}}
+
+	if p, ok := doc.FirstPoint(); !ok || p.Latitude != 1 {
+		t.Errorf("got %v, %t first point; expected latitude 1", p.Latitude, ok)
+	}
+	if p, ok := doc.LastPoint(); !ok || p.Latitude != 3 {
+		t.Errorf("got %v, %t last point; expected latitude 3", p.Latitude, ok)
+	}
+	if _, ok := (Document{}).FirstPoint(); ok {
+		t.Error("expected no first point for an empty document")
+	}
+	if _, ok := (Document{}).LastPoint(); ok {
+		t.Error("expected no last point for an empty document")
+	}
+}
+
+func TestDecoderCopyrightYearAttribute(t *testing.T) {
+	f, err := os.Open("test/copyright_attr.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := NewDecoder(f).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := 2019; doc.Metadata.Copyright.Year != expected {
+		t.Errorf("got %d copyright year; expected %d", doc.Metadata.Copyright.Year, expected)
+	}
+	if expected := "https://example.com/jane"; doc.Metadata.Author.Link.Href != expected {
+		t.Errorf("got %q author link; expected %q", doc.Metadata.Author.Link.Href, expected)
+	}
+}
+
+func TestDecoderCopyrightNoYear(t *testing.T) {
+	f, err := os.Open("test/copyright_no_year.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := NewDecoder(f).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Metadata.Copyright.Year != 0 {
+		t.Errorf("got %d copyright year; expected 0", doc.Metadata.Copyright.Year)
+	}
+	if expected := "Jane Doe"; doc.Metadata.Copyright.Author != expected {
+		t.Errorf("got %q copyright author; expected %q", doc.Metadata.Copyright.Author, expected)
+	}
+}
+
+func TestDecoderWithPointFilter(t *testing.T) {
+	f, err := os.Open("test/test.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	filter := func(p Point) (Point, bool) {
+		n++
+		p.Elevation = math.Round(p.Elevation)
+		return p, n%2 == 1
+	}
+	doc, err := NewDecoder(f, WithPointFilter(filter)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 9 {
+		t.Errorf("filter called %d time(s); expected 9", n)
+	}
+	points := doc.AllPoints()
+	if l := len(points); l != 5 {
+		t.Fatalf("got %d point(s); expected 5", l)
+	}
+	for _, p := range points {
+		if p.Elevation != math.Round(p.Elevation) {
+			t.Errorf("got %v elevation; expected a rounded value", p.Elevation)
+		}
+	}
+}
+
+func TestSegmentDurationBetween(t *testing.T) {
+	t0 := time.Date(2015, 12, 13, 18, 0, 0, 0, time.UTC)
+	seg := Segment{Points: []Point{
+		{Time: t0},
+		{Time: t0.Add(10 * time.Second)},
+		{},
+		{Time: t0.Add(30 * time.Second)},
+	}}
+
+	testCases := []struct {
+		i, j     int
+		duration time.Duration
+	}{
+		{0, 3, 30 * time.Second},
+		{3, 1, -20 * time.Second},
+		{1, 1, 0},
+		{0, 2, 0},
+	}
+	for _, tc := range testCases {
+		d, err := seg.DurationBetween(tc.i, tc.j)
+		if err != nil {
+			t.Errorf("unexpected error for %d..%d: %s", tc.i, tc.j, err)
+		}
+		if d != tc.duration {
+			t.Errorf("got %v between %d and %d; expected %v", d, tc.i, tc.j, tc.duration)
+		}
+	}
+
+	if _, err := seg.DurationBetween(0, 4); err == nil {
+		t.Error("expected an error for an out of range index")
+	}
+	if _, err := seg.DurationBetween(-1, 0); err == nil {
+		t.Error("expected an error for a negative index")
+	}
+}
+
+func TestTrackTimeRange(t *testing.T) {
+	t0 := time.Date(2015, 12, 13, 18, 0, 0, 0, time.UTC)
+	track := Track{Segments: []Segment{
+		{Points: []Point{{Time: t0.Add(time.Minute)}, {}}},
+		{Points: []Point{{Time: t0}, {Time: t0.Add(time.Hour)}, {}}},
+	}}
+
+	start, end, ok := track.TimeRange()
+	if !ok || !start.Equal(t0) || !end.Equal(t0.Add(time.Hour)) {
+		t.Errorf("got %v - %v (%v); expected %v - %v", start, end, ok, t0, t0.Add(time.Hour))
+	}
+	if _, _, ok := (Track{}).TimeRange(); ok {
+		t.Error("expected no time range for an empty track")
+	}
+}
+
+func TestDecoderEmptyElevation(t *testing.T) {
+	f, err := os.Open("test/empty_ele.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := NewDecoder(f).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	points := doc.AllPoints()
+	if l := len(points); l != 4 {
+		t.Fatalf("got %d point(s); expected 4", l)
+	}
+	for i, expected := range []bool{false, false, true, true} {
+		if points[i].HasElevation != expected {
+			t.Errorf("got %v elevation presence for point %d; expected %v", points[i].HasElevation, i, expected)
+		}
+	}
+	if expected := 349.4727478027344; points[3].Elevation != expected {
+		t.Errorf("got %v elevation; expected %v", points[3].Elevation, expected)
+	}
+}
+
+func TestDocumentExtentDiagonal(t *testing.T) {
+	doc := FromPoints([]Point{
+		{Latitude: 0, Longitude: 1},
+		{Latitude: 1, Longitude: 0},
+		{Latitude: 0.5, Longitude: 0.5},
+	})
+	expected := haversine(0, 0, 1, 1)
+	if d := doc.ExtentDiagonal(); d != expected {
+		t.Errorf("got %v extent diagonal; expected %v", d, expected)
+	}
+	if d := (Document{}).ExtentDiagonal(); d != 0 {
+		t.Errorf("got %v extent diagonal for an empty document; expected 0", d)
+	}
+}
+
+func TestDecoderWithLogger(t *testing.T) {
+	const data = `<gpx version="1.1" xmlns="http://www.topografix.com/GPX/1/1"><trk><trkseg><trkpt lat="north" lon="11.12"></trkpt><trkpt lat="49.39" lon="11.12"></trkpt>`
+
+	var msgs []string
+	d := NewDecoder(strings.NewReader(data), WithLogger(func(msg string) {
+		msgs = append(msgs, msg)
+	}))
+	d.Strict = false
+	if _, err := d.Decode(); err != ErrTruncated {
+		t.Fatalf("got %v error; expected %v", err, ErrTruncated)
+	}
+
+	if len(msgs) != 2 {
+		t.Fatalf("got %d message(s); expected 2: %q", len(msgs), msgs)
+	}
+	if !strings.HasPrefix(msgs[0], "gpx: invalid <trkpt> lat") {
+		t.Errorf("got %q message; expected an invalid latitude", msgs[0])
+	}
+	if msgs[1] != ErrTruncated.Error() {
+		t.Errorf("got %q message; expected %q", msgs[1], ErrTruncated.Error())
+	}
+
+	msgs = nil
+	if _, err := NewDecoder(strings.NewReader(data), WithLogger(func(msg string) {
+		msgs = append(msgs, msg)
+	})).Decode(); err == nil {
+		t.Error("expected strict decoding to fail")
+	}
+	if len(msgs) != 0 {
+		t.Errorf("got %q message(s) in strict mode; expected none", msgs)
+	}
+}
+
+func TestDecoderWaypoints(t *testing.T) {
+	f, err := os.Open("test/waypoints.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := NewDecoder(f).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l := len(doc.Waypoints); l != 2 {
+		t.Fatalf("got %d waypoint(s); expected 2", l)
+	}
+	if l := len(doc.Tracks); l != 0 {
+		t.Errorf("got %d track(s); expected 0", l)
+	}
+
+	w := doc.Waypoints[0]
+	w.Extensions = nil
+	expected := Waypoint{
+		Latitude:     51.0543422,
+		Longitude:    3.7174243,
+		Elevation:    12.5,
+		HasElevation: true,
+		Time:         time.Date(2015, 12, 13, 18, 35, 18, 0, time.UTC),
+		Name:         "Belfry",
+		Description:  "Medieval belfry of Ghent",
+		Symbol:       "Flag, Blue",
+		Type:         "landmark",
+	}
+	if !reflect.DeepEqual(w, expected) {
+		t.Errorf("got %+v waypoint; expected %+v", w, expected)
+	}
+
+	ext, err := ParseGarminWaypointExtension(doc.Waypoints[0].Extensions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := 25.0; ext.Proximity != expected {
+		t.Errorf("got %v proximity; expected %v", ext.Proximity, expected)
+	}
+
+	w = doc.Waypoints[1]
+	if w.HasElevation || !w.Time.IsZero() || w.Name != "GC12345" || w.Symbol != "Geocache" {
+		t.Errorf("got %+v waypoint; expected GC12345 without elevation and time", w)
+	}
+}
+
+func TestDecoderRoutes(t *testing.T) {
+	f, err := os.Open("test/routes.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := NewDecoder(f).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l := len(doc.Routes); l != 2 {
+		t.Fatalf("got %d route(s); expected 2", l)
+	}
+	testCases := []struct {
+		name   string
+		points int
+	}{
+		{"To the station", 3},
+		{"Back home", 2},
+	}
+	for i, tc := range testCases {
+		r := doc.Routes[i]
+		if r.Name != tc.name {
+			t.Errorf("got %q route name; expected %q", r.Name, tc.name)
+		}
+		if l := len(r.Points); l != tc.points {
+			t.Errorf("got %d point(s) in route %q; expected %d", l, r.Name, tc.points)
+		}
+	}
+
+	p := doc.Routes[0].Points[0]
+	if !p.HasElevation || p.Elevation != 12.5 || p.Latitude != 51.0543422 {
+		t.Errorf("got %+v first route point; expected 51.0543422, 3.7174243 at 12.5m", p)
+	}
+	if expected := time.Date(2015, 12, 13, 18, 35, 18, 0, time.UTC); !doc.Routes[0].Points[2].Time.Equal(expected) {
+		t.Errorf("got %v route point time; expected %v", doc.Routes[0].Points[2].Time, expected)
+	}
+}
+
+func TestDecoderQualityFields(t *testing.T) {
+	f, err := os.Open("test/quality.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := NewDecoder(f).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	points := doc.AllPoints()
+	if l := len(points); l != 3 {
+		t.Fatalf("got %d point(s); expected 3", l)
+	}
+	testCases := []struct {
+		fix              string
+		sat              uint
+		hdop, vdop, pdop float64
+	}{
+		{"3d", 9, 0.9, 1.4, 1.7},
+		{"rtk", 4, 3.5, 0, 0},
+		{"", 0, 0, 0, 0},
+	}
+	for i, tc := range testCases {
+		p := points[i]
+		if p.Fix != tc.fix || p.Satellites != tc.sat || p.HDOP != tc.hdop || p.VDOP != tc.vdop || p.PDOP != tc.pdop {
+			t.Errorf("got fix %q, %d sat(s), hdop %v, vdop %v, pdop %v for point %d; expected %+v", p.Fix, p.Satellites, p.HDOP, p.VDOP, p.PDOP, i, tc)
+		}
+	}
+}
+
+func TestDecoderWarnings(t *testing.T) {
+	f, err := os.Open("test/warnings.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDecoder(f)
+	d.Strict = false
+	doc, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	points := doc.AllPoints()
+	if l := len(points); l != 3 {
+		t.Fatalf("got %d point(s); expected 3", l)
+	}
+	if points[0].HasElevation || !points[1].Time.IsZero() {
+		t.Error("expected the invalid elevation and time to be missing")
+	}
+
+	warnings := d.Warnings()
+	if l := len(warnings); l != 2 {
+		t.Fatalf("got %d warning(s); expected 2", l)
+	}
+	for i, expected := range []struct{ element, value string }{{"ele", "high"}, {"time", "yesterday"}} {
+		w := warnings[i]
+		if w.Element != expected.element || w.Value != expected.value || w.Err == nil {
+			t.Errorf("got %+v warning; expected <%s> %q with an error", w, expected.element, expected.value)
+		}
+	}
+
+	f.Seek(0, io.SeekStart)
+	d = NewDecoder(f)
+	if _, err := d.Decode(); err == nil {
+		t.Error("expected strict decoding to fail")
+	}
+	if l := len(d.Warnings()); l != 0 {
+		t.Errorf("got %d warning(s) in strict mode; expected 0", l)
+	}
+}
+
+func TestNewDecoderAuto(t *testing.T) {
+	data, err := ioutil.ReadFile("test/test.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write(data)
+	zw.Close()
+
+	var docs []Document
+	for _, input := range [][]byte{data, compressed.Bytes()} {
+		d, err := NewDecoderAuto(bytes.NewReader(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc, err := d.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		docs = append(docs, doc)
+	}
+
+	if l := len(docs[0].AllPoints()); l != 9 {
+		t.Errorf("got %d point(s); expected 9", l)
+	}
+	if !reflect.DeepEqual(docs[0], docs[1]) {
+		t.Error("expected the plain and gzipped documents to be equal")
+	}
+
+	if _, err := NewDecoderAuto(bytes.NewReader([]byte{0x1f, 0x8b, 0})); err == nil {
+		t.Error("expected an error for a corrupt gzip header")
+	}
+}
+
+func TestDecodeContext(t *testing.T) {
+	track := GenerateTrack(GenerateOptions{
+		Points:  100000,
+		Spacing: 10,
+		Start:   Point{Latitude: 51, Longitude: 3.7, Time: time.Date(2015, 12, 13, 18, 0, 0, 0, time.UTC)},
+		Step:    time.Second,
+	})
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(track.Document()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var n int
+	cancelAfter := func(p Point) (Point, bool) {
+		n++
+		if n == 10 {
+			cancel()
+		}
+		return p, true
+	}
+
+	_, err := NewDecoder(bytes.NewReader(buf.Bytes()), WithPointFilter(cancelAfter)).DecodeContext(ctx)
+	if err != context.Canceled {
+		t.Fatalf("got %v error; expected %v", err, context.Canceled)
+	}
+	if n >= 1000 {
+		t.Errorf("decoded %d point(s) after canceling; expected decoding to stop promptly", n)
+	}
+
+	doc, err := NewDecoder(bytes.NewReader(buf.Bytes())).DecodeContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := len(doc.AllPoints()); l != 100000 {
+		t.Errorf("got %d point(s); expected 100000", l)
+	}
+}
+
+func TestDecoderPreserveRaw(t *testing.T) {
+	f, err := os.Open("test/test.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	d := NewDecoder(f)
+	d.PreserveRaw = true
+	doc, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := doc.Tracks[0].Segments[0].Points[0]
+	for _, raw := range []struct {
+		name, got, expected string
+		value               float64
+	}{
+		{"latitude", p.RawLatitude, "49.3973693847656250", p.Latitude},
+		{"longitude", p.RawLongitude, "11.1259574890136719", p.Longitude},
+		{"elevation", p.RawElevation, "346.874267578125", p.Elevation},
+	} {
+		if raw.got != raw.expected {
+			t.Errorf("got %q raw %s; expected %q", raw.got, raw.name, raw.expected)
+		}
+		if f, _ := strconv.ParseFloat(raw.expected, 64); raw.value != f {
+			t.Errorf("got %v %s; expected %v", raw.value, raw.name, f)
+		}
+	}
+
+	if p := decodeFile(t, "test/test.gpx").Tracks[0].Segments[0].Points[0]; p.RawLatitude != "" || p.RawElevation != "" {
+		t.Error("expected no raw values by default")
+	}
+}
+
+func TestDecoderReset(t *testing.T) {
+	data, err := ioutil.ReadFile("test/test.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDecoder(strings.NewReader(`<gpx version="1.1" xmlns="http://www.topografix.com/GPX/1/1"><wpt lat="north" lon="3.7"/></gpx>`))
+	d.Strict = false
+	if _, err := d.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	if l := len(d.Warnings()); l != 1 {
+		t.Fatalf("got %d warning(s); expected 1", l)
+	}
+
+	d.Reset(bytes.NewReader(data))
+	first, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := len(d.Warnings()); l != 0 {
+		t.Errorf("got %d warning(s) after a reset; expected 0", l)
+	}
+	if d.Strict {
+		t.Error("expected Strict to be kept across resets")
+	}
+
+	d.Reset(bytes.NewReader(data))
+	second, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("got %+v decoding again after a reset; expected %+v", second, first)
+	}
+}
+
+func TestDecoderSyntaxError(t *testing.T) {
+	const data = `<gpx version="1.1" xmlns="http://www.topografix.com/GPX/1/1"><trk><trkseg><trkpt lat="49.39" lon="11.12"/><trkpt lat="north" lon="11.13"/></trkseg></trk></gpx>`
+
+	_, err := NewDecoder(strings.NewReader(data)).Decode()
+	serr, ok := err.(*SyntaxError)
+	if !ok {
+		t.Fatalf("got %v error; expected a *SyntaxError", err)
+	}
+	if serr.Element != "trkpt" {
+		t.Errorf("got %q element; expected trkpt", serr.Element)
+	}
+	start := int64(strings.Index(data, `<trkpt lat="north"`))
+	end := start + int64(len(`<trkpt lat="north" lon="11.13"/>`))
+	if serr.Offset < start || serr.Offset > end {
+		t.Errorf("got %d offset; expected between %d and %d", serr.Offset, start, end)
+	}
+	var w Warning
+	if !errors.As(err, &w) || w.Attribute != "lat" || w.Value != "north" {
+		t.Errorf("got %+v warning; expected the invalid lat", w)
+	}
+	if !strings.Contains(err.Error(), "invalid <trkpt> lat") {
+		t.Errorf("got %q error message; expected it to name the attribute", err)
+	}
+
+	for _, el := range []string{
+		`<metadata><bounds minlat="49" minlon="11" maxlat="50" maxlon="12"><foo/></bounds></metadata>`,
+		`<metadata><author><email id="me" domain="example.com"><foo/></email></author></metadata>`,
+	} {
+		_, err := NewDecoder(strings.NewReader(`<gpx version="1.1" xmlns="http://www.topografix.com/GPX/1/1">` + el + `</gpx>`)).Decode()
+		if serr, ok := err.(*SyntaxError); !ok || serr.Offset == 0 {
+			t.Errorf("got %v error for an element that isn't self-closing; expected a *SyntaxError", err)
+		}
+	}
+}
+
+func TestDecoderMaxPoints(t *testing.T) {
+	const data = `<gpx version="1.1" xmlns="http://www.topografix.com/GPX/1/1">%s</gpx>`
+
+	wpts := strings.Repeat(`<wpt lat="51" lon="3.7"/>`, 11)
+	if _, err := NewDecoder(strings.NewReader(fmt.Sprintf(data, wpts)), WithMaxPoints(10)).Decode(); err != ErrTooManyPoints {
+		t.Errorf("got %v error for too many waypoints; expected %v", err, ErrTooManyPoints)
+	}
+
+	mixed := strings.Repeat(`<wpt lat="51" lon="3.7"/>`, 5) + `<trk><trkseg>` + strings.Repeat(`<trkpt lat="51" lon="3.7"/>`, 5) + `</trkseg></trk>`
+	if _, err := NewDecoder(strings.NewReader(fmt.Sprintf(data, mixed)), WithMaxPoints(10)).Decode(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestDecoderMaxBytes(t *testing.T) {
+	data, err := ioutil.ReadFile("test/test.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDecoder(bytes.NewReader(data), WithMaxBytes(int64(len(data)/2)))
+	d.Strict = false
+	if _, err := d.Decode(); err != ErrTooLarge {
+		t.Errorf("got %v error; expected %v", err, ErrTooLarge)
+	}
+
+	if _, err := NewDecoder(bytes.NewReader(data), WithMaxBytes(int64(len(data)))).Decode(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestDecoderResetBufferedReader(t *testing.T) {
+	data, err := ioutil.ReadFile("test/test.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const trailer = "trailing data"
+	br := bufio.NewReader(io.MultiReader(bytes.NewReader(data), strings.NewReader(trailer)))
+	d := NewDecoder(br)
+	first, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d.Reset(bufio.NewReader(bytes.NewReader(data)))
+	second, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("got %+v decoding again after a reset; expected %+v", second, first)
+	}
+	if err := d.DecodeInto(&second); err == nil {
+		t.Error("expected an error decoding past the end of the input")
+	}
+
+	rest, err := ioutil.ReadAll(br)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(rest), trailer) {
+		t.Errorf("got %q left in the caller's reader; expected it to end in %q", rest, trailer)
 	}
 }
@@ -0,0 +1,63 @@
+package gpx
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+var (
+	ErrUnsupportedContentType = errors.New("gpx: unsupported content type")
+)
+
+// DefaultMaxPoints and DefaultMaxBytes limit the documents DecodeRequest
+// accepts unless overridden with WithMaxPoints and WithMaxBytes.
+const (
+	DefaultMaxPoints = 1000000
+	DefaultMaxBytes  = 64 << 20
+)
+
+// contentTypes holds the media types DecodeRequest accepts.
+var contentTypes = map[string]bool{
+	"application/gpx+xml":      true,
+	"application/gpx":          true,
+	"application/xml":          true,
+	"text/xml":                 true,
+	"application/octet-stream": true,
+}
+
+// DecodeRequest decodes the body of an HTTP request carrying a GPX document,
+// configured by opts. Requests without a Content-Type header or with an XML,
+// GPX or generic binary content type are accepted; others fail with
+// ErrUnsupportedContentType. Gzip-compressed bodies, as announced by the
+// Content-Encoding header, are decompressed. Documents with more than
+// DefaultMaxPoints points or DefaultMaxBytes bytes once decompressed are
+// rejected, unless opts set other limits.
+func DecodeRequest(r *http.Request, opts ...Option) (Document, error) {
+	opts = append([]Option{WithMaxPoints(DefaultMaxPoints), WithMaxBytes(DefaultMaxBytes)}, opts...)
+
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mediaType, _, err := mime.ParseMediaType(ct)
+		if err != nil || !contentTypes[mediaType] {
+			return Document{}, ErrUnsupportedContentType
+		}
+	}
+
+	body := r.Body
+	switch ce := strings.ToLower(r.Header.Get("Content-Encoding")); ce {
+	case "", "identity":
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			return Document{}, err
+		}
+		defer zr.Close()
+		return NewDecoder(zr, opts...).Decode()
+	default:
+		return Document{}, fmt.Errorf("gpx: unsupported content encoding %q", ce)
+	}
+	return NewDecoder(body, opts...).Decode()
+}
@@ -0,0 +1,89 @@
+package gpx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDecodeRequest(t *testing.T) {
+	data, err := ioutil.ReadFile("test/test.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("POST", "/upload", bytes.NewReader(data))
+	r.Header.Set("Content-Type", "application/gpx+xml; charset=utf-8")
+	doc, err := DecodeRequest(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := len(doc.AllPoints()); l != 9 {
+		t.Errorf("got %d point(s); expected 9", l)
+	}
+
+	r = httptest.NewRequest("POST", "/upload", bytes.NewReader(data))
+	r.Header.Set("Content-Type", "application/json")
+	if _, err := DecodeRequest(r); err != ErrUnsupportedContentType {
+		t.Errorf("got %v error; expected %v", err, ErrUnsupportedContentType)
+	}
+}
+
+func TestDecodeRequestGzip(t *testing.T) {
+	data, err := ioutil.ReadFile("test/test.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+
+	r := httptest.NewRequest("POST", "/upload", bytes.NewReader(buf.Bytes()))
+	r.Header.Set("Content-Encoding", "gzip")
+	doc, err := DecodeRequest(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := len(doc.AllPoints()); l != 9 {
+		t.Errorf("got %d point(s); expected 9", l)
+	}
+
+	r = httptest.NewRequest("POST", "/upload", bytes.NewReader(data))
+	r.Header.Set("Content-Encoding", "br")
+	if _, err := DecodeRequest(r); err == nil {
+		t.Error("expected an error for an unsupported content encoding")
+	}
+}
+
+func TestDecodeRequestMaxPoints(t *testing.T) {
+	data, err := ioutil.ReadFile("test/test.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("POST", "/upload", bytes.NewReader(data))
+	if _, err := DecodeRequest(r, WithMaxPoints(5)); err != ErrTooManyPoints {
+		t.Errorf("got %v error; expected %v", err, ErrTooManyPoints)
+	}
+
+	r = httptest.NewRequest("POST", "/upload", bytes.NewReader(data))
+	if _, err := DecodeRequest(r, WithMaxPoints(9)); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestDecodeRequestMaxBytes(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(bytes.Repeat([]byte(" "), 10<<20))
+	zw.Close()
+
+	r := httptest.NewRequest("POST", "/upload", bytes.NewReader(buf.Bytes()))
+	r.Header.Set("Content-Encoding", "gzip")
+	if _, err := DecodeRequest(r, WithMaxBytes(1<<20)); err != ErrTooLarge {
+		t.Errorf("got %v error; expected %v", err, ErrTooLarge)
+	}
+}
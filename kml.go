@@ -0,0 +1,63 @@
+package gpx
+
+import (
+	"bytes"
+)
+
+const kmlNS = "http://www.opengis.net/kml/2.2"
+
+// ToKML returns the document as a KML document, as read by Google Earth.
+// Every track becomes a Placemark holding a LineString of its points, or a
+// MultiGeometry of LineStrings when the track has several segments. The
+// metadata description is used as the document's description.
+func (d Document) ToKML() ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	buf.WriteString(`<kml xmlns="` + kmlNS + `"><Document>`)
+	if d.Metadata.Name != "" {
+		buf.WriteString("<name>" + textEscaper.Replace(d.Metadata.Name) + "</name>")
+	}
+	if d.Metadata.Description != "" {
+		buf.WriteString("<description>" + textEscaper.Replace(d.Metadata.Description) + "</description>")
+	}
+	for _, t := range d.Tracks {
+		buf.WriteString("<Placemark>")
+		if t.Name != "" {
+			buf.WriteString("<name>" + textEscaper.Replace(t.Name) + "</name>")
+		}
+		if len(t.Segments) != 1 {
+			buf.WriteString("<MultiGeometry>")
+		}
+		for _, s := range t.Segments {
+			writeKMLLineString(&buf, s)
+		}
+		if len(t.Segments) != 1 {
+			buf.WriteString("</MultiGeometry>")
+		}
+		buf.WriteString("</Placemark>")
+	}
+	buf.WriteString("</Document></kml>\n")
+
+	return buf.Bytes(), nil
+}
+
+// writeKMLLineString writes the points of s as a LineString, with its
+// coordinates as space separated lon,lat,ele tuples. The altitude is left
+// out for points without an elevation.
+func writeKMLLineString(buf *bytes.Buffer, s Segment) {
+	buf.WriteString("<LineString><coordinates>")
+	for i, p := range s.Points {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(formatFloat(p.Longitude))
+		buf.WriteByte(',')
+		buf.WriteString(formatFloat(p.Latitude))
+		if p.hasElevation() {
+			buf.WriteByte(',')
+			buf.WriteString(formatFloat(p.Elevation))
+		}
+	}
+	buf.WriteString("</coordinates></LineString>")
+}
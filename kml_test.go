@@ -0,0 +1,75 @@
+package gpx
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestToKML(t *testing.T) {
+	doc := decodeFile(t, "test/test.gpx")
+
+	data, err := doc.ToKML()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var kml struct {
+		Document struct {
+			Description string `xml:"description"`
+			Placemarks  []struct {
+				Name        string `xml:"name"`
+				Coordinates string `xml:"LineString>coordinates"`
+			} `xml:"Placemark"`
+		}
+	}
+	if err := xml.Unmarshal(data, &kml); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "Running in the forest"; kml.Document.Description != expected {
+		t.Errorf("got %q description; expected %q", kml.Document.Description, expected)
+	}
+	if l := len(kml.Document.Placemarks); l != 1 {
+		t.Fatalf("got %d placemark(s); expected 1", l)
+	}
+	placemark := kml.Document.Placemarks[0]
+	if expected := "Running"; placemark.Name != expected {
+		t.Errorf("got %q name; expected %q", placemark.Name, expected)
+	}
+
+	points := doc.Tracks[0].Segments[0].Points
+	tuples := strings.Fields(placemark.Coordinates)
+	if len(tuples) != len(points) {
+		t.Fatalf("got %d coordinate(s); expected %d", len(tuples), len(points))
+	}
+	for i, tuple := range tuples {
+		var coord []float64
+		for _, f := range strings.Split(tuple, ",") {
+			v, err := strconv.ParseFloat(f, 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			coord = append(coord, v)
+		}
+		if expected := []float64{points[i].Longitude, points[i].Latitude, points[i].Elevation}; !equalFloats(coord, expected) {
+			t.Errorf("got %v coordinate %d; expected %v", coord, i, expected)
+		}
+	}
+}
+
+func TestToKMLWithoutElevation(t *testing.T) {
+	doc := FromPoints([]Point{
+		{Latitude: 49.39, Longitude: 11.12},
+		{Latitude: 49.4, Longitude: 11.13, HasElevation: true},
+	})
+
+	data, err := doc.ToKML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "<coordinates>11.12,49.39 11.13,49.4,0</coordinates>"; !strings.Contains(string(data), expected) {
+		t.Errorf("expected %q in\n%s", expected, data)
+	}
+}
@@ -0,0 +1,139 @@
+package gpx
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// An Option configures a Decoder.
+type Option func(*Decoder)
+
+// WithCharsetReader sets the function used to convert documents declaring
+// a non-UTF-8 encoding to UTF-8. It has the semantics of
+// xml.Decoder.CharsetReader. By default ISO-8859-1 (latin1) and US-ASCII
+// are supported out of the box.
+func WithCharsetReader(f func(charset string, input io.Reader) (io.Reader, error)) Option {
+	return func(d *Decoder) {
+		d.charsetReader = f
+	}
+}
+
+// WithoutExtensions makes the decoder skip <extensions> elements of the
+// metadata, waypoints, tracks and points instead of retaining their tokens.
+// This saves memory and allocations when the extensions aren't used.
+func WithoutExtensions() Option {
+	return func(d *Decoder) {
+		d.skipExtensions = true
+	}
+}
+
+// WithTimeTruncate makes the decoder truncate every parsed time to a
+// multiple of resolution, e.g. time.Second to drop sub-second jitter. By
+// default times keep their full precision.
+func WithTimeTruncate(resolution time.Duration) Option {
+	return func(d *Decoder) {
+		d.timeTruncate = resolution
+	}
+}
+
+// WithPointFilter makes the decoder pass every track point through filter
+// before adding it to its segment. The point returned by filter is kept
+// unless the second return value is false, in which case the point is
+// dropped. This allows downsampling, cleaning or reprojecting points without
+// holding the unfiltered document in memory. By default every point is kept
+// as is.
+func WithPointFilter(filter func(Point) (Point, bool)) Option {
+	return func(d *Decoder) {
+		d.pointFilter = filter
+	}
+}
+
+// WithLogger sets a function called with a description of every problem
+// the decoder tolerates in non-strict mode, such as an unparsable
+// coordinate or a truncated document. By default nothing is logged.
+func WithLogger(logger func(msg string)) Option {
+	return func(d *Decoder) {
+		d.logger = logger
+	}
+}
+
+// WithMaxPoints makes the decoder fail with ErrTooManyPoints as soon as the
+// document holds more than n waypoints, route points and track points,
+// guarding against documents exhausting memory. By default the number of
+// points is unlimited.
+func WithMaxPoints(n int) Option {
+	return func(d *Decoder) {
+		d.maxPoints = n
+	}
+}
+
+// WithMaxBytes makes the decoder fail with ErrTooLarge as soon as it has
+// read more than n bytes of input, after decompression by the caller. By
+// default the input size is unlimited.
+func WithMaxBytes(n int64) Option {
+	return func(d *Decoder) {
+		d.maxBytes = n
+	}
+}
+
+// defaultCharsetReader converts ISO-8859-1 and US-ASCII input to UTF-8.
+func defaultCharsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "iso8859-1", "latin1", "l1":
+		return &latin1Reader{r: input}, nil
+	case "us-ascii", "ascii":
+		return input, nil
+	}
+	return nil, fmt.Errorf("gpx: unsupported charset %q", charset)
+}
+
+// A latin1Reader converts ISO-8859-1 input to UTF-8.
+type latin1Reader struct {
+	r   io.Reader
+	buf []byte
+}
+
+func (lr *latin1Reader) Read(p []byte) (int, error) {
+	// Every latin1 byte encodes to at most two UTF-8 bytes.
+	n := len(p) / 2
+	if n == 0 {
+		if len(p) == 0 {
+			return 0, nil
+		}
+		return 0, io.ErrShortBuffer
+	}
+	if cap(lr.buf) < n {
+		lr.buf = make([]byte, n)
+	}
+	n, err := lr.r.Read(lr.buf[:n])
+	var w int
+	for _, b := range lr.buf[:n] {
+		w += utf8.EncodeRune(p[w:], rune(b))
+	}
+	return w, err
+}
+
+// A maxBytesReader reads from r, failing with ErrTooLarge once more than n
+// bytes have been read.
+type maxBytesReader struct {
+	r io.Reader
+	n int64
+}
+
+func (mr *maxBytesReader) Read(p []byte) (int, error) {
+	if mr.n < 0 {
+		return 0, ErrTooLarge
+	}
+	if int64(len(p)) > mr.n+1 {
+		p = p[:mr.n+1]
+	}
+	n, err := mr.r.Read(p)
+	mr.n -= int64(n)
+	if mr.n < 0 {
+		return n + int(mr.n), ErrTooLarge
+	}
+	return n, err
+}
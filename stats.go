@@ -0,0 +1,1028 @@
+package gpx
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// normalizedPowerWindow is the rolling average window used for normalized
+// power.
+const normalizedPowerWindow = 30
+
+// NormalizedPower returns the document's normalized power in watts. The power
+// series from Garmin’s Power extension is resampled to one sample per second
+// (holding the last recorded value), smoothed with a 30 second rolling
+// average, raised to the fourth power, averaged and finally the fourth root
+// is taken. Each segment is resampled separately so pauses between segments
+// don't contribute. The second return value is false when the document has
+// no timestamped power data.
+func (d Document) NormalizedPower() (float64, bool) {
+	var sum float64
+	var n int
+	for _, t := range d.Tracks {
+		for _, s := range t.Segments {
+			for _, avg := range rollingPower(s, normalizedPowerWindow) {
+				sum += math.Pow(avg, 4)
+				n++
+			}
+		}
+	}
+	if n == 0 {
+		return 0, false
+	}
+	return math.Pow(sum/float64(n), 0.25), true
+}
+
+// IntensityFactor returns the document's normalized power divided by the
+// functional threshold power ftp. The second return value is false when the
+// document has no power data or ftp isn't positive.
+func (d Document) IntensityFactor(ftp float64) (float64, bool) {
+	np, ok := d.NormalizedPower()
+	if !ok || ftp <= 0 {
+		return 0, false
+	}
+	return np / ftp, true
+}
+
+// rollingPower resamples the segment's power to one sample per second and
+// returns the rolling averages over window seconds. A series shorter than
+// the window yields its plain average.
+func rollingPower(s Segment, window int) []float64 {
+	var series []float64
+	var last Point
+	var lastPower float64
+	for _, p := range s.Points {
+		if p.Time.IsZero() {
+			continue
+		}
+		ext, err := ParseGarminPowerExtension(p.Extensions)
+		if err != nil {
+			continue
+		}
+		if !last.Time.IsZero() {
+			for t := last.Time.Add(time.Second); t.Before(p.Time); t = t.Add(time.Second) {
+				series = append(series, lastPower)
+			}
+		}
+		lastPower = float64(ext.Power)
+		series = append(series, lastPower)
+		last = p
+	}
+
+	if len(series) == 0 {
+		return nil
+	}
+	if len(series) < window {
+		var sum float64
+		for _, v := range series {
+			sum += v
+		}
+		return []float64{sum / float64(len(series))}
+	}
+
+	averages := make([]float64, 0, len(series)-window+1)
+	var sum float64
+	for i, v := range series {
+		sum += v
+		if i >= window {
+			sum -= series[i-window]
+		}
+		if i >= window-1 {
+			averages = append(averages, sum/float64(window))
+		}
+	}
+	return averages
+}
+
+// Pause represents a gap in a segment's recording. Index is the index of
+// the first point after the gap.
+type Pause struct {
+	Index    int
+	Duration time.Duration
+}
+
+// DetectPauses returns the gaps between consecutive timestamped points
+// that exceed minGap. Devices using smart recording log points at irregular
+// intervals, so minGap should be chosen well above their usual interval to
+// distinguish real stops from sparse logging.
+func (s Segment) DetectPauses(minGap time.Duration) []Pause {
+	var pauses []Pause
+	for i := 1; i < len(s.Points); i++ {
+		prev, p := s.Points[i-1], s.Points[i]
+		if prev.Time.IsZero() || p.Time.IsZero() {
+			continue
+		}
+		if gap := p.Time.Sub(prev.Time); gap > minGap {
+			pauses = append(pauses, Pause{Index: i, Duration: gap})
+		}
+	}
+	return pauses
+}
+
+// A GradeCost returns the relative energy cost of running on a gradient
+// (rise over run, e.g. 0.1 for 10%). Only the ratio to the cost on flat
+// ground (GradeCost(0)) is used.
+type GradeCost func(grade float64) float64
+
+// MinettiGradeCost is the energy cost of running (J/kg/m) on a gradient as
+// measured by Minetti et al. (2002). Gradients are clamped to ±45%, the
+// range the polynomial was fitted on.
+func MinettiGradeCost(grade float64) float64 {
+	g := math.Max(-0.45, math.Min(0.45, grade))
+	return 155.4*math.Pow(g, 5) - 30.4*math.Pow(g, 4) - 43.3*math.Pow(g, 3) + 46.3*g*g + 19.5*g + 3.6
+}
+
+// GradeAdjustedPace returns the document's grade-adjusted pace per kilometer
+// using MinettiGradeCost.
+func (d Document) GradeAdjustedPace() time.Duration {
+	return d.GradeAdjustedPaceWithCost(MinettiGradeCost)
+}
+
+// GradeAdjustedPaceWithCost returns the pace per kilometer the document's
+// effort would have yielded on flat ground. The distance of each pair of
+// consecutive timestamped points is scaled by cost(grade)/cost(0) and the
+// total time is divided by the resulting equivalent flat distance. Pairs
+// with a point lacking an elevation count as flat. Zero is returned when
+// there is no such distance.
+func (d Document) GradeAdjustedPaceWithCost(cost GradeCost) time.Duration {
+	var flatDistance float64
+	var duration time.Duration
+	flat := cost(0)
+	for _, t := range d.Tracks {
+		for _, s := range t.Segments {
+			for i := 1; i < len(s.Points); i++ {
+				prev, p := s.Points[i-1], s.Points[i]
+				if prev.Time.IsZero() || p.Time.IsZero() {
+					continue
+				}
+				dist := prev.DistanceTo(p)
+				if dist == 0 {
+					continue
+				}
+				grade, ok := edgeGrade(prev, p)
+				if !ok {
+					grade = 0
+				}
+				flatDistance += dist * cost(grade) / flat
+				duration += p.Time.Sub(prev.Time)
+			}
+		}
+	}
+	if flatDistance <= 0 {
+		return 0
+	}
+	return time.Duration(float64(duration) / (flatDistance / 1000))
+}
+
+// AverageHeartRate returns the average heart rate (beats per minute) of the
+// segment's points carrying Garmin’s TrackPoint extension. The second
+// return value is false when there is no heart rate data.
+func (s Segment) AverageHeartRate() (uint, bool) {
+	var sum, n uint
+	for _, hr := range s.heartRates() {
+		sum += hr
+		n++
+	}
+	if n == 0 {
+		return 0, false
+	}
+	return sum / n, true
+}
+
+// MaxHeartRate returns the maximum heart rate (beats per minute) of the
+// segment's points carrying Garmin’s TrackPoint extension. The second
+// return value is false when there is no heart rate data.
+func (s Segment) MaxHeartRate() (uint, bool) {
+	var max uint
+	hrs := s.heartRates()
+	for _, hr := range hrs {
+		if hr > max {
+			max = hr
+		}
+	}
+	return max, len(hrs) > 0
+}
+
+// heartRates returns the heart rates recorded for the segment's points.
+func (s Segment) heartRates() []uint {
+	var hrs []uint
+	for _, p := range s.Points {
+		ext, err := ParseGarminTrackPointExtension(p.Extensions)
+		if err != nil || ext.HeartRate == 0 {
+			continue
+		}
+		hrs = append(hrs, ext.HeartRate)
+	}
+	return hrs
+}
+
+// MovingAverageCadence returns the average cadence (revs per minute) of the
+// document's points carrying Garmin’s TrackPoint extension, counting only
+// the points MovingMask classifies as moving at speedThreshold meters per
+// second. Points recording a cadence of zero are ignored. The second return
+// value is false when there is no cadence data for moving points.
+func (d Document) MovingAverageCadence(speedThreshold float64) (uint, bool) {
+	var sum, n uint
+	for _, t := range d.Tracks {
+		for _, s := range t.Segments {
+			moving := s.MovingMask(speedThreshold)
+			for i, p := range s.Points {
+				if !moving[i] {
+					continue
+				}
+				ext, err := ParseGarminTrackPointExtension(p.Extensions)
+				if err != nil || ext.Cadence == 0 {
+					continue
+				}
+				sum += ext.Cadence
+				n++
+			}
+		}
+	}
+	if n == 0 {
+		return 0, false
+	}
+	return sum / n, true
+}
+
+// ElevationGainRange returns the document's total elevation gain in meters
+// computed with and without ignoring changes smaller than threshold meters.
+// Jitter in recorded elevations inflates the unfiltered gain, so the two
+// values bound the actual gain: low is the filtered and high the
+// unfiltered gain.
+func (d Document) ElevationGainRange(threshold float64) (low, high float64) {
+	low, _ = d.elevationChange(threshold)
+	high, _ = d.elevationChange(0)
+	return low, high
+}
+
+// ElevationGain returns the document's total elevation gain in meters,
+// summing every climb between consecutive points of each segment. Points
+// without elevation are skipped.
+func (d Document) ElevationGain() float64 {
+	return d.ElevationGainThreshold(0)
+}
+
+// ElevationLoss returns the document's total elevation loss in meters,
+// summing every descent between consecutive points of each segment. Points
+// without elevation are skipped.
+func (d Document) ElevationLoss() float64 {
+	return d.ElevationLossThreshold(0)
+}
+
+// ElevationGainThreshold returns the document's total elevation gain in
+// meters like ElevationGain, but ignores changes smaller than minDelta
+// meters to filter out GPS noise. Each elevation is compared to the last
+// one that counted, so a slow steady climb still adds up.
+func (d Document) ElevationGainThreshold(minDelta float64) float64 {
+	gain, _ := d.elevationChange(minDelta)
+	return gain
+}
+
+// ElevationLossThreshold returns the document's total elevation loss in
+// meters like ElevationLoss, ignoring changes smaller than minDelta meters
+// the way ElevationGainThreshold does.
+func (d Document) ElevationLossThreshold(minDelta float64) float64 {
+	_, loss := d.elevationChange(minDelta)
+	return loss
+}
+
+// ClimbRate returns the document's elevation gain in meters per kilometer
+// of distance, an indicator of how hilly it is regardless of its length.
+// Documents without distance have a climb rate of zero.
+func (d Document) ClimbRate() float64 {
+	km := d.DistanceInKilometers()
+	if km == 0 {
+		return 0
+	}
+	return d.ElevationGain() / km
+}
+
+// elevationChange returns the document's total elevation gain and loss in
+// meters. Within each segment the elevation is compared to the last
+// elevation that counted, and a change only counts once it reaches
+// threshold meters. Points without elevation are skipped.
+func (d Document) elevationChange(threshold float64) (gain, loss float64) {
+	for _, t := range d.Tracks {
+		for _, s := range t.Segments {
+			var ref float64
+			var started bool
+			for _, p := range s.Points {
+				if !p.hasElevation() {
+					continue
+				}
+				if !started {
+					ref, started = p.Elevation, true
+					continue
+				}
+				delta := p.Elevation - ref
+				if delta == 0 || math.Abs(delta) < threshold {
+					continue
+				}
+				if delta > 0 {
+					gain += delta
+				} else {
+					loss -= delta
+				}
+				ref = p.Elevation
+			}
+		}
+	}
+	return gain, loss
+}
+
+// IndoorMaxDistance is the total distance in meters below which a document
+// with recorded time and sensor data is considered an indoor activity.
+const IndoorMaxDistance = 100.0
+
+// IsIndoor reports whether the document looks like an indoor (e.g.
+// treadmill or trainer) activity: its total distance is below
+// IndoorMaxDistance while it has a positive duration and at least one
+// point carries extension data such as heart rate.
+func (d Document) IsIndoor() bool {
+	return d.DistanceInMeters() < IndoorMaxDistance && d.Duration() > 0 && d.HasExtension()
+}
+
+// MedianInterval returns the median time between consecutive timestamped
+// points of the segment, or zero if there are none.
+func (s Segment) MedianInterval() time.Duration {
+	return medianDuration(s.intervals())
+}
+
+// MedianInterval returns the median time between consecutive timestamped
+// points within the document's segments, or zero if there are none.
+func (d Document) MedianInterval() time.Duration {
+	var intervals []time.Duration
+	for _, t := range d.Tracks {
+		for _, s := range t.Segments {
+			intervals = append(intervals, s.intervals()...)
+		}
+	}
+	return medianDuration(intervals)
+}
+
+// EstimatedPauseTime returns the time the device wasn't logging, estimated
+// as the sum of the excess of every interval between consecutive
+// timestamped points over normalInterval. If normalInterval isn't
+// positive, the document's MedianInterval is used.
+func (d Document) EstimatedPauseTime(normalInterval time.Duration) time.Duration {
+	if normalInterval <= 0 {
+		normalInterval = d.MedianInterval()
+	}
+	var pause time.Duration
+	for _, t := range d.Tracks {
+		for _, s := range t.Segments {
+			for _, interval := range s.intervals() {
+				if interval > normalInterval {
+					pause += interval - normalInterval
+				}
+			}
+		}
+	}
+	return pause
+}
+
+// IsRegularlySampled reports whether the standard deviation of the time
+// between consecutive timestamped points is at most tolerance, i.e. whether
+// the segment was recorded at a steady rate. Segments with fewer than two
+// timestamped points aren't regularly sampled.
+func (s Segment) IsRegularlySampled(tolerance time.Duration) bool {
+	intervals := s.intervals()
+	if len(intervals) == 0 {
+		return false
+	}
+	var mean float64
+	for _, i := range intervals {
+		mean += float64(i)
+	}
+	mean /= float64(len(intervals))
+	var variance float64
+	for _, i := range intervals {
+		variance += (float64(i) - mean) * (float64(i) - mean)
+	}
+	variance /= float64(len(intervals))
+	return math.Sqrt(variance) <= float64(tolerance)
+}
+
+// intervals returns the time between consecutive timestamped points.
+func (s Segment) intervals() []time.Duration {
+	var intervals []time.Duration
+	for i := 1; i < len(s.Points); i++ {
+		prev, p := s.Points[i-1], s.Points[i]
+		if prev.Time.IsZero() || p.Time.IsZero() {
+			continue
+		}
+		intervals = append(intervals, p.Time.Sub(prev.Time))
+	}
+	return intervals
+}
+
+func medianDuration(durations []time.Duration) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	sorted := make([]time.Duration, len(durations))
+	copy(sorted, durations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// TimeWeightedAverageSpeed returns the document's average speed in meters
+// per second, weighting the speed between each pair of consecutive
+// timestamped points by the time between them. This equals the distance
+// covered between timestamped points divided by the time it took, and
+// avoids over-weighting short intervals. Zero is returned when there is no
+// elapsed time.
+func (d Document) TimeWeightedAverageSpeed() float64 {
+	var weighted float64
+	var total time.Duration
+	for _, t := range d.Tracks {
+		for _, s := range t.Segments {
+			for i := 1; i < len(s.Points); i++ {
+				prev, p := s.Points[i-1], s.Points[i]
+				speed, ok := edgeSpeed(prev, p)
+				if !ok {
+					continue
+				}
+				dt := p.Time.Sub(prev.Time)
+				weighted += speed * dt.Seconds()
+				total += dt
+			}
+		}
+	}
+	if total <= 0 {
+		return 0
+	}
+	return weighted / total.Seconds()
+}
+
+// AverageSpeed returns the document's average speed in meters per second:
+// its total distance divided by its total duration, pauses included. Use
+// MovingTime for a speed that excludes stops, or Trimmed to drop the
+// erratic points recorded while the GPS fix settles. Zero is returned when
+// there is no elapsed time.
+func (d Document) AverageSpeed() float64 {
+	duration := d.Duration()
+	if duration <= 0 {
+		return 0
+	}
+	return d.DistanceInMeters() / duration.Seconds()
+}
+
+// SpeedOptions configures which intervals between consecutive points
+// MaxSpeedWithOptions considers. Very short intervals are where GPS jitter
+// produces implausible speeds. The zero value considers every interval.
+type SpeedOptions struct {
+	MinDuration time.Duration // Time between points below which an interval is ignored
+	MinDistance float64       // Distance in meters below which an interval is ignored
+	Trim        Trim          // Points to drop from the ends of each segment first
+}
+
+// MaxSpeed returns the highest speed in meters per second between two
+// consecutive timestamped points of a segment, or zero if there is none.
+func (d Document) MaxSpeed() float64 {
+	return d.MaxSpeedWithOptions(SpeedOptions{})
+}
+
+// MaxSpeedWithOptions is like MaxSpeed, but ignores the intervals opts
+// excludes. Points sharing a timestamp are always ignored.
+func (d Document) MaxSpeedWithOptions(opts SpeedOptions) float64 {
+	var max float64
+	for _, t := range d.Tracks {
+		for _, s := range t.Segments {
+			s = s.trimmed(opts.Trim)
+			for i := 1; i < len(s.Points); i++ {
+				prev, p := s.Points[i-1], s.Points[i]
+				speed, ok := edgeSpeed(prev, p)
+				if !ok || p.Time.Sub(prev.Time) < opts.MinDuration || prev.DistanceTo(p) < opts.MinDistance {
+					continue
+				}
+				if speed > max {
+					max = speed
+				}
+			}
+		}
+	}
+	return max
+}
+
+// FlatGrade is the gradient (rise over run) below which the terrain
+// between two points is considered flat rather than climbing or
+// descending.
+const FlatGrade = 0.01
+
+// AscentDistance returns the horizontal distance in meters covered while
+// climbing, i.e. between consecutive points whose gradient exceeds
+// FlatGrade.
+func (d Document) AscentDistance() float64 {
+	return d.slopeDistance(1)
+}
+
+// DescentDistance returns the horizontal distance in meters covered while
+// descending, i.e. between consecutive points whose gradient is below
+// -FlatGrade.
+func (d Document) DescentDistance() float64 {
+	return d.slopeDistance(-1)
+}
+
+// slopeDistance returns the distance covered between consecutive points
+// with the given slope.
+func (d Document) slopeDistance(slope int) float64 {
+	var distance float64
+	for _, t := range d.Tracks {
+		for _, s := range t.Segments {
+			for i := 1; i < len(s.Points); i++ {
+				prev, p := s.Points[i-1], s.Points[i]
+				if edgeSlope(prev, p) == slope {
+					distance += prev.DistanceTo(p)
+				}
+			}
+		}
+	}
+	return distance
+}
+
+// DistanceAboveGrade returns the distance in meters covered between
+// consecutive points whose gradient exceeds grade percent.
+func (d Document) DistanceAboveGrade(grade float64) float64 {
+	var distance float64
+	for _, t := range d.Tracks {
+		for _, s := range t.Segments {
+			for i := 1; i < len(s.Points); i++ {
+				prev, p := s.Points[i-1], s.Points[i]
+				if g, ok := edgeGrade(prev, p); ok && g*100 > grade {
+					distance += prev.DistanceTo(p)
+				}
+			}
+		}
+	}
+	return distance
+}
+
+// edgeSlope classifies the terrain between p1 and p2 as climbing (1),
+// descending (-1) or flat (0) using FlatGrade.
+func edgeSlope(p1, p2 Point) int {
+	grade, ok := edgeGrade(p1, p2)
+	if !ok {
+		return 0
+	}
+	switch {
+	case grade > FlatGrade:
+		return 1
+	case grade < -FlatGrade:
+		return -1
+	}
+	return 0
+}
+
+// DominantBearing returns the distance-weighted circular mean of the
+// bearings between consecutive points in degrees [0, 360), summarizing in
+// which direction the document mostly went. Zero is returned when there is
+// no movement.
+func (d Document) DominantBearing() float64 {
+	var x, y float64
+	for _, t := range d.Tracks {
+		for _, s := range t.Segments {
+			for i := 1; i < len(s.Points); i++ {
+				prev, p := s.Points[i-1], s.Points[i]
+				dist := prev.DistanceTo(p)
+				if dist == 0 {
+					continue
+				}
+				b := prev.BearingTo(p) * (math.Pi / 180.0)
+				x += dist * math.Cos(b)
+				y += dist * math.Sin(b)
+			}
+		}
+	}
+	if x == 0 && y == 0 {
+		return 0
+	}
+	return math.Mod(math.Atan2(y, x)*(180.0/math.Pi)+360, 360)
+}
+
+// TurnCount returns the number of times the segment's direction changes by
+// more than minAngle degrees. The bearings between consecutive points are
+// first smoothed with a circular moving average over three edges, so a
+// single jittery position doesn't count as a turn. A turn is counted when
+// the smoothed bearing deviates more than minAngle from the bearing after
+// the previous turn, so turns spread over several points count once and
+// jitter doesn't add up. Consecutive points at the same position are
+// skipped.
+func (s Segment) TurnCount(minAngle float64) int {
+	var bearings []float64
+	for i := 1; i < len(s.Points); i++ {
+		prev, p := s.Points[i-1], s.Points[i]
+		if prev.DistanceTo(p) == 0 {
+			continue
+		}
+		bearings = append(bearings, prev.BearingTo(p)*(math.Pi/180.0))
+	}
+	if len(bearings) < 2 {
+		return 0
+	}
+
+	smoothed := make([]float64, len(bearings))
+	for i := range bearings {
+		var x, y float64
+		for j := i - 1; j <= i+1; j++ {
+			if j >= 0 && j < len(bearings) {
+				x += math.Cos(bearings[j])
+				y += math.Sin(bearings[j])
+			}
+		}
+		smoothed[i] = math.Atan2(y, x) * (180.0 / math.Pi)
+	}
+
+	var turns int
+	ref := smoothed[0]
+	for _, b := range smoothed[1:] {
+		if math.Abs(angleDiff(b, ref)) > minAngle {
+			turns++
+			ref = b
+		}
+	}
+	return turns
+}
+
+// angleDiff returns the signed difference a - b between two angles in
+// degrees, normalized to [-180, 180).
+func angleDiff(a, b float64) float64 {
+	return math.Mod(math.Mod(a-b+180, 360)+360, 360) - 180
+}
+
+// SteepestKm returns the start (as a distance in meters from the start of
+// the document) and average gradient in percent of the steepest climbing
+// kilometer. Points without an elevation are skipped. When the points with
+// an elevation span less than a kilometer, their average gradient is
+// returned along with the start of the first of them.
+func (d Document) SteepestKm() (startDist float64, avgGrade float64) {
+	const window = 1000.0
+
+	dists, eles := d.profile()
+	if len(dists) < 2 {
+		return 0, 0
+	}
+	span := dists[len(dists)-1] - dists[0]
+	if span == 0 {
+		return 0, 0
+	}
+	if span < window {
+		return dists[0], (eles[len(eles)-1] - eles[0]) / span * 100
+	}
+
+	best := math.Inf(-1)
+	for i := range dists {
+		end, ok := interpolate(dists, eles, dists[i]+window)
+		if !ok {
+			break
+		}
+		if grade := (end - eles[i]) / window * 100; grade > best {
+			startDist, best = dists[i], grade
+		}
+	}
+	return startDist, best
+}
+
+// ElevationAt returns the elevation at distance meters from the start of
+// the document, linearly interpolated between the surrounding points with
+// an elevation. The second return value is false when distance lies
+// outside of the document or before the first or after the last point with
+// an elevation, including when no point has one.
+func (d Document) ElevationAt(distance float64) (float64, bool) {
+	dists, eles := d.profile()
+	return interpolate(dists, eles, distance)
+}
+
+// profile returns the cumulative distance in meters and the elevation of
+// every track point with an elevation in document order. Points without one
+// still add to the distance; gaps between segments don't.
+func (d Document) profile() (dists, eles []float64) {
+	var dist float64
+	for _, t := range d.Tracks {
+		for _, s := range t.Segments {
+			for i, p := range s.Points {
+				if i > 0 {
+					dist += s.Points[i-1].DistanceTo(p)
+				}
+				if !p.hasElevation() {
+					continue
+				}
+				dists = append(dists, dist)
+				eles = append(eles, p.Elevation)
+			}
+		}
+	}
+	return dists, eles
+}
+
+// interpolate linearly interpolates ys at x given ascending xs. The second
+// return value is false when x lies outside of xs.
+func interpolate(xs, ys []float64, x float64) (float64, bool) {
+	if len(xs) == 0 || x < xs[0] || x > xs[len(xs)-1] {
+		return 0, false
+	}
+	i := sort.SearchFloat64s(xs, x)
+	if xs[i] == x {
+		return ys[i], true
+	}
+	frac := (x - xs[i-1]) / (xs[i] - xs[i-1])
+	return ys[i-1] + frac*(ys[i]-ys[i-1]), true
+}
+
+// Split represents one split of a document.
+type Split struct {
+	Index    int           // Index of the split, starting at 0
+	Distance float64       // Distance of the split in meters
+	Duration time.Duration // Time taken for the split
+	Pace     time.Duration // Average pace of the split per kilometer
+}
+
+// Splits splits the document every distance meters and returns the time
+// and pace of each split. The time at which a split boundary was crossed
+// is interpolated between the surrounding points. The last split covers the
+// remaining distance and may be shorter. Only timestamped points are
+// considered; nil is returned when there are fewer than two.
+func (d Document) Splits(distance float64) []Split {
+	dists, secs := d.timeProfile()
+	if len(dists) < 2 || distance <= 0 {
+		return nil
+	}
+	start, total := dists[0], dists[len(dists)-1]
+
+	var splits []Split
+	prevDist, prevSec := start, secs[0]
+	for i := 0; prevDist < total; i++ {
+		dist := math.Min(start+float64(i+1)*distance, total)
+		sec, _ := interpolate(dists, secs, dist)
+		split := Split{
+			Index:    i,
+			Distance: dist - prevDist,
+			Duration: time.Duration((sec - prevSec) * float64(time.Second)),
+		}
+		split.Pace = time.Duration(float64(split.Duration) / (split.Distance / 1000))
+		splits = append(splits, split)
+		prevDist, prevSec = dist, sec
+	}
+	return splits
+}
+
+// IsNegativeSplit reports whether the second half of the document (by
+// distance) was covered faster than the first half. The time at the
+// halfway point is interpolated between the surrounding points. False is
+// returned when there are fewer than two timestamped points.
+func (d Document) IsNegativeSplit() bool {
+	dists, secs := d.timeProfile()
+	if len(dists) < 2 || dists[len(dists)-1] == dists[0] {
+		return false
+	}
+	mid, _ := interpolate(dists, secs, (dists[0]+dists[len(dists)-1])/2)
+	return secs[len(secs)-1]-mid < mid-secs[0]
+}
+
+// timeProfile returns the cumulative distance in meters and the time in
+// seconds since the first timestamped point of every timestamped track
+// point in document order. Points without a timestamp only add to the
+// distance, gaps between segments don't.
+func (d Document) timeProfile() (dists, secs []float64) {
+	var start time.Time
+	var dist float64
+	for _, t := range d.Tracks {
+		for _, s := range t.Segments {
+			for i, p := range s.Points {
+				if i > 0 {
+					dist += s.Points[i-1].DistanceTo(p)
+				}
+				if p.Time.IsZero() {
+					continue
+				}
+				if start.IsZero() {
+					start = p.Time
+				}
+				dists = append(dists, dist)
+				secs = append(secs, p.Time.Sub(start).Seconds())
+			}
+		}
+	}
+	return dists, secs
+}
+
+// MovingMask returns for every point of the segment whether it is moving,
+// i.e. whether the speed from the previous point is at least
+// speedThreshold meters per second. Points whose speed can't be computed,
+// because either point lacks a timestamp or they share one, take the state
+// of the previous point. The first point takes the state of the second.
+func (s Segment) MovingMask(speedThreshold float64) []bool {
+	mask := make([]bool, len(s.Points))
+	for i := 1; i < len(s.Points); i++ {
+		if speed, ok := edgeSpeed(s.Points[i-1], s.Points[i]); ok {
+			mask[i] = speed >= speedThreshold
+		} else {
+			mask[i] = mask[i-1]
+		}
+	}
+	if len(mask) > 1 {
+		mask[0] = mask[1]
+	}
+	return mask
+}
+
+// MovingDistance returns the distance in meters covered while moving,
+// summing the distance to every point MovingMask classifies as moving at
+// speedThreshold meters per second. Unlike DistanceInMeters it leaves out
+// the distance GPS drift adds while standing still.
+func (d Document) MovingDistance(speedThreshold float64) float64 {
+	var distance float64
+	for _, t := range d.Tracks {
+		for _, s := range t.Segments {
+			moving := s.MovingMask(speedThreshold)
+			for i := 1; i < len(s.Points); i++ {
+				if moving[i] {
+					distance += s.Points[i-1].DistanceTo(s.Points[i])
+				}
+			}
+		}
+	}
+	return distance
+}
+
+// MovingOptions configures which intervals MovingTime counts as moving.
+// The zero value counts every interval.
+type MovingOptions struct {
+	MinSpeed float64       // Speed in meters per second below which an interval is a pause
+	MaxGap   time.Duration // Time between points above which an interval is a pause
+	Trim     Trim          // Points to drop from the ends of each segment first
+}
+
+// MovingTime returns the time spent moving: the sum of the intervals
+// between consecutive timestamped points of each segment that opts don't
+// classify as a pause. Unlike Duration it excludes stops such as traffic
+// lights and rest breaks. Points sharing a timestamp and segments with a
+// single point add no time.
+func (d Document) MovingTime(opts MovingOptions) time.Duration {
+	var moving time.Duration
+	for _, t := range d.Tracks {
+		for _, s := range t.Segments {
+			s = s.trimmed(opts.Trim)
+			for i := 1; i < len(s.Points); i++ {
+				prev, p := s.Points[i-1], s.Points[i]
+				speed, ok := edgeSpeed(prev, p)
+				if !ok {
+					continue
+				}
+				dt := p.Time.Sub(prev.Time)
+				if speed < opts.MinSpeed || (opts.MaxGap > 0 && dt > opts.MaxGap) {
+					continue
+				}
+				moving += dt
+			}
+		}
+	}
+	return moving
+}
+
+// edgeSpeed returns the speed in meters per second from p1 to p2. The
+// second return value is false when either point lacks a timestamp or no
+// time passed between them.
+func edgeSpeed(p1, p2 Point) (float64, bool) {
+	if p1.Time.IsZero() || p2.Time.IsZero() {
+		return 0, false
+	}
+	dt := p2.Time.Sub(p1.Time).Seconds()
+	if dt <= 0 {
+		return 0, false
+	}
+	return p1.DistanceTo(p2) / dt, true
+}
+
+// DefaultMovingSpeed is the speed in meters per second below which
+// statistics without an explicit threshold consider a point stopped.
+const DefaultMovingSpeed = 0.5
+
+// TimeOfDayHistogram returns the document's moving time bucketed by the
+// hour of the day in loc (UTC if nil). Intervals between consecutive
+// points are considered moving when their speed is at least
+// DefaultMovingSpeed, and are split across the hours they span. All
+// buckets are zero when there are no timestamps.
+func (d Document) TimeOfDayHistogram(loc *time.Location) [24]time.Duration {
+	if loc == nil {
+		loc = time.UTC
+	}
+	var histogram [24]time.Duration
+	for _, t := range d.Tracks {
+		for _, s := range t.Segments {
+			for i := 1; i < len(s.Points); i++ {
+				prev, p := s.Points[i-1], s.Points[i]
+				speed, ok := edgeSpeed(prev, p)
+				if !ok || speed < DefaultMovingSpeed {
+					continue
+				}
+				from, to := prev.Time.In(loc), p.Time.In(loc)
+				for from.Before(to) {
+					next := time.Date(from.Year(), from.Month(), from.Day(), from.Hour()+1, 0, 0, 0, loc)
+					if next.After(to) {
+						next = to
+					}
+					histogram[from.Hour()] += next.Sub(from)
+					from = next
+				}
+			}
+		}
+	}
+	return histogram
+}
+
+// edgeGrade returns the gradient (rise over run) between p1 and p2. The
+// second return value is false when either point lacks an elevation or the
+// points share a position.
+func edgeGrade(p1, p2 Point) (float64, bool) {
+	if !p1.hasElevation() || !p2.hasElevation() {
+		return 0, false
+	}
+	dist := p1.DistanceTo(p2)
+	if dist == 0 {
+		return 0, false
+	}
+	return (p2.Elevation - p1.Elevation) / dist, true
+}
+
+// NoiseLevel returns the average distance in meters between each interior
+// point and the great circle through its two neighbours. A straight, evenly
+// recorded segment scores close to zero; jittery GPS recordings score higher.
+// Segments with fewer than three points return zero.
+func (s Segment) NoiseLevel() float64 {
+	if len(s.Points) < 3 {
+		return 0
+	}
+	var total float64
+	for i := 1; i < len(s.Points)-1; i++ {
+		prev, p, next := s.Points[i-1], s.Points[i], s.Points[i+1]
+		total += crossTrack(prev.Latitude, prev.Longitude, next.Latitude, next.Longitude, p.Latitude, p.Longitude)
+	}
+	return total / float64(len(s.Points)-2)
+}
+
+// DefaultMaxInterpolationGap is the largest gap between two consecutive
+// points that PointAt interpolates across.
+const DefaultMaxInterpolationGap = 5 * time.Minute
+
+// PointAt returns the position at t, linearly interpolated between the two
+// points surrounding t. See PointAtWithMaxGap for details; PointAt uses
+// DefaultMaxInterpolationGap.
+func (d Document) PointAt(t time.Time) (Point, bool) {
+	return d.PointAtWithMaxGap(t, DefaultMaxInterpolationGap)
+}
+
+// PointAtWithMaxGap returns the position at t. Latitude, longitude and
+// elevation are linearly interpolated by time between the two timestamped
+// points surrounding t within a segment; a point recorded exactly at t is
+// returned as is. The second return value is false when t lies outside of
+// every segment, between two segments or inside a gap longer than maxGap,
+// so no straight line is drawn through a pause. A maxGap of zero or less
+// disables the gap check.
+func (d Document) PointAtWithMaxGap(t time.Time, maxGap time.Duration) (Point, bool) {
+	for _, track := range d.Tracks {
+		for _, s := range track.Segments {
+			if p, ok := s.pointAt(t, maxGap); ok {
+				return p, true
+			}
+		}
+	}
+	return Point{}, false
+}
+
+func (s Segment) pointAt(t time.Time, maxGap time.Duration) (Point, bool) {
+	var prev Point
+	for _, p := range s.Points {
+		if p.Time.IsZero() {
+			continue
+		}
+		if p.Time.Equal(t) {
+			return p, true
+		}
+		if !prev.Time.IsZero() && prev.Time.Before(t) && t.Before(p.Time) {
+			gap := p.Time.Sub(prev.Time)
+			if maxGap > 0 && gap > maxGap {
+				return Point{}, false
+			}
+			f := float64(t.Sub(prev.Time)) / float64(gap)
+			point := Point{
+				Latitude:  prev.Latitude + f*(p.Latitude-prev.Latitude),
+				Longitude: prev.Longitude + f*(p.Longitude-prev.Longitude),
+				Time:      t,
+			}
+			if prev.hasElevation() && p.hasElevation() {
+				point.Elevation = prev.Elevation + f*(p.Elevation-prev.Elevation)
+				point.HasElevation = true
+			}
+			return point, true
+		}
+		prev = p
+	}
+	return Point{}, false
+}
@@ -0,0 +1,774 @@
+package gpx
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"testing"
+	"time"
+)
+
+// powerPoint returns a point at t carrying a Garmin power extension.
+func powerPoint(t time.Time, watts uint) Point {
+	return Point{
+		Time:       t,
+		Extensions: extensionTokens(fmt.Sprintf(`<gpxpx:PowerInWatts xmlns:gpxpx="%s">%d</gpxpx:PowerInWatts>`, GarminPowerExtensionNS, watts)),
+	}
+}
+
+func TestNormalizedPower(t *testing.T) {
+	if _, ok := (Document{}).NormalizedPower(); ok {
+		t.Error("expected no normalized power for an empty document")
+	}
+
+	t0 := time.Date(2015, 12, 13, 18, 0, 0, 0, time.UTC)
+	var points []Point
+	for i := 0; i < 60; i++ {
+		points = append(points, powerPoint(t0.Add(time.Duration(i)*time.Second), 200))
+	}
+	for i := 60; i < 120; i++ {
+		points = append(points, powerPoint(t0.Add(time.Duration(i)*time.Second), 300))
+	}
+	doc := FromPoints(points)
+
+	np, ok := doc.NormalizedPower()
+	if !ok {
+		t.Fatal("expected normalized power")
+	}
+	if np <= 250 || np >= 300 {
+		t.Errorf("got %f normalized power; expected between average and max power", np)
+	}
+
+	constant := FromPoints(points[:60])
+	if np, _ := constant.NormalizedPower(); math.Abs(np-200) > 1e-9 {
+		t.Errorf("got %f normalized power; expected 200", np)
+	}
+	if ifactor, _ := constant.IntensityFactor(250); math.Abs(ifactor-0.8) > 1e-9 {
+		t.Errorf("got %f intensity factor; expected 0.8", ifactor)
+	}
+}
+
+func TestDetectPauses(t *testing.T) {
+	t0 := time.Date(2015, 12, 13, 18, 0, 0, 0, time.UTC)
+	seg := Segment{Points: []Point{
+		{Time: t0},
+		{Time: t0.Add(5 * time.Second)},
+		{Time: t0.Add(2 * time.Minute)},
+		{},
+		{Time: t0.Add(2*time.Minute + 5*time.Second)},
+	}}
+
+	pauses := seg.DetectPauses(30 * time.Second)
+	if l := len(pauses); l != 1 {
+		t.Fatalf("got %d pause(s); expected 1", l)
+	}
+	if expected := (Pause{Index: 2, Duration: 115 * time.Second}); pauses[0] != expected {
+		t.Errorf("got %+v pause; expected %+v", pauses[0], expected)
+	}
+}
+
+func TestGradeAdjustedPace(t *testing.T) {
+	start := Point{
+		Latitude:  49.3973693847656250,
+		Longitude: 11.1259574890136719,
+		Time:      time.Date(2015, 12, 13, 18, 35, 18, 0, time.UTC),
+	}
+	opts := GenerateOptions{Points: 101, Spacing: 10, Start: start, Step: 3 * time.Second}
+
+	flat := Document{Tracks: []Track{GenerateTrack(opts)}}
+	if pace, expected := flat.GradeAdjustedPace(), 5*time.Minute; pace < expected-time.Millisecond || pace > expected+time.Millisecond {
+		t.Errorf("got %s flat pace; expected %s", pace, expected)
+	}
+
+	climb := Document{Tracks: []Track{GenerateTrack(opts)}}
+	for i := range climb.Tracks[0].Segments[0].Points {
+		climb.Tracks[0].Segments[0].Points[i].Elevation = float64(i)
+		climb.Tracks[0].Segments[0].Points[i].HasElevation = true
+	}
+	if pace := climb.GradeAdjustedPace(); pace >= 5*time.Minute {
+		t.Errorf("got %s pace uphill; expected faster than flat pace", pace)
+	}
+}
+
+func TestSegmentHeartRate(t *testing.T) {
+	f, err := os.Open("test/test.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := NewDecoder(f).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seg := doc.Tracks[0].Segments[0]
+
+	if hr, ok := seg.AverageHeartRate(); !ok || hr != 140 {
+		t.Errorf("got %d average heart rate; expected 140", hr)
+	}
+	if hr, ok := seg.MaxHeartRate(); !ok || hr != 171 {
+		t.Errorf("got %d max heart rate; expected 171", hr)
+	}
+
+	if _, ok := (Segment{}).AverageHeartRate(); ok {
+		t.Error("expected no average heart rate for an empty segment")
+	}
+	if _, ok := (Segment{}).MaxHeartRate(); ok {
+		t.Error("expected no max heart rate for an empty segment")
+	}
+}
+
+func TestElevationGainRange(t *testing.T) {
+	var points []Point
+	for _, ele := range []float64{100, 101, 100, 101, 100, 110, 109, 110, 120} {
+		points = append(points, Point{Elevation: ele, HasElevation: true})
+	}
+	doc := FromPoints(points)
+
+	low, high := doc.ElevationGainRange(3)
+	if low != 20 {
+		t.Errorf("got %f low gain; expected 20", low)
+	}
+	if high != 23 {
+		t.Errorf("got %f high gain; expected 23", high)
+	}
+}
+
+func TestIsIndoor(t *testing.T) {
+	f, err := os.Open("test/test.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := NewDecoder(f).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.IsIndoor() {
+		t.Error("expected outdoor run not to be indoor")
+	}
+
+	points := doc.AllPoints()
+	for i := range points {
+		points[i].Latitude = points[0].Latitude
+		points[i].Longitude = points[0].Longitude
+	}
+	if !FromPoints(points).IsIndoor() {
+		t.Error("expected stationary run with heart rate to be indoor")
+	}
+}
+
+func TestEstimatedPauseTime(t *testing.T) {
+	t0 := time.Date(2015, 12, 13, 18, 0, 0, 0, time.UTC)
+	var points []Point
+	for _, offset := range []int{0, 1, 2, 3, 13, 14, 15, 45} {
+		points = append(points, Point{Time: t0.Add(time.Duration(offset) * time.Second)})
+	}
+	doc := FromPoints(points)
+
+	if median := doc.MedianInterval(); median != time.Second {
+		t.Errorf("got %s median interval; expected 1s", median)
+	}
+	if pause := doc.EstimatedPauseTime(0); pause != 38*time.Second {
+		t.Errorf("got %s pause time; expected 38s", pause)
+	}
+	if pause := doc.EstimatedPauseTime(5 * time.Second); pause != 30*time.Second {
+		t.Errorf("got %s pause time; expected 30s", pause)
+	}
+}
+
+func TestTimeWeightedAverageSpeed(t *testing.T) {
+	if speed := (Document{}).TimeWeightedAverageSpeed(); speed != 0 {
+		t.Errorf("got %f speed for an empty document; expected 0", speed)
+	}
+
+	t0 := time.Date(2015, 12, 13, 18, 0, 0, 0, time.UTC)
+	track := GenerateTrack(GenerateOptions{Points: 3, Spacing: 100, Start: Point{Latitude: 49.4, Longitude: 11.1}})
+	points := track.Segments[0].Points
+	points[0].Time = t0
+	points[1].Time = t0.Add(10 * time.Second)
+	points[2].Time = t0.Add(60 * time.Second)
+
+	if speed := (Document{Tracks: []Track{track}}).TimeWeightedAverageSpeed(); math.Abs(speed-200.0/60) > 1e-6 {
+		t.Errorf("got %f speed; expected %f", speed, 200.0/60)
+	}
+}
+
+func TestSpeed(t *testing.T) {
+	if speed := (Document{}).AverageSpeed(); speed != 0 {
+		t.Errorf("got %f speed for an empty document; expected 0", speed)
+	}
+
+	t0 := time.Date(2015, 12, 13, 18, 0, 0, 0, time.UTC)
+	track := GenerateTrack(GenerateOptions{Points: 5, Spacing: 100, Start: Point{Latitude: 49.4, Longitude: 11.1}})
+	points := track.Segments[0].Points
+	for i, secs := range []int{0, 1, 11, 61, 61} {
+		points[i].Time = t0.Add(time.Duration(secs) * time.Second)
+	}
+	doc := Document{Tracks: []Track{track}}
+
+	if speed := doc.AverageSpeed(); math.Abs(speed-400.0/61) > 1e-6 {
+		t.Errorf("got %f average speed; expected %f", speed, 400.0/61)
+	}
+	if speed := doc.MaxSpeed(); math.Abs(speed-100) > 1e-6 {
+		t.Errorf("got %f max speed; expected 100", speed)
+	}
+	if speed := doc.MaxSpeedWithOptions(SpeedOptions{MinDuration: 5 * time.Second}); math.Abs(speed-10) > 1e-6 {
+		t.Errorf("got %f max speed ignoring short intervals; expected 10", speed)
+	}
+	if speed := doc.MaxSpeedWithOptions(SpeedOptions{MinDistance: 150}); speed != 0 {
+		t.Errorf("got %f max speed ignoring short distances; expected 0", speed)
+	}
+	if speed := doc.MaxSpeedWithOptions(SpeedOptions{Trim: Trim{Points: 1}}); math.Abs(speed-10) > 1e-6 {
+		t.Errorf("got %f max speed after trimming; expected 10", speed)
+	}
+}
+
+func TestAscentDescentDistance(t *testing.T) {
+	track := GenerateTrack(GenerateOptions{Points: 5, Spacing: 100, Start: Point{Latitude: 49.4, Longitude: 11.1}})
+	for i, ele := range []float64{100, 110, 110.5, 100, 90} {
+		track.Segments[0].Points[i].Elevation = ele
+		track.Segments[0].Points[i].HasElevation = true
+	}
+	doc := Document{Tracks: []Track{track}}
+
+	if dist := doc.AscentDistance(); math.Abs(dist-100) > 1e-6 {
+		t.Errorf("got %f ascent distance; expected 100", dist)
+	}
+	if dist := doc.DescentDistance(); math.Abs(dist-200) > 1e-6 {
+		t.Errorf("got %f descent distance; expected 200", dist)
+	}
+}
+
+func TestDominantBearing(t *testing.T) {
+	north := GenerateTrack(GenerateOptions{Points: 11, Spacing: 100, Bearing: 0, Start: Point{Latitude: 49.4, Longitude: 11.1}})
+	end := north.Segments[0].Points[10]
+	east := GenerateTrack(GenerateOptions{Points: 3, Spacing: 100, Bearing: 90, Start: end})
+	doc := Document{Tracks: []Track{north, east}}
+
+	expected := math.Atan2(200, 1000) * 180 / math.Pi
+	if b := doc.DominantBearing(); math.Abs(b-expected) > 0.1 {
+		t.Errorf("got %f dominant bearing; expected %f", b, expected)
+	}
+}
+
+func TestSteepestKm(t *testing.T) {
+	track := GenerateTrack(GenerateOptions{Points: 31, Spacing: 100, Start: Point{Latitude: 49.4, Longitude: 11.1}})
+	for i := range track.Segments[0].Points {
+		// Flat for 1 km, then 8% for 1 km, then 4% for 1 km.
+		switch {
+		case i <= 10:
+			track.Segments[0].Points[i].Elevation = 100
+		case i <= 20:
+			track.Segments[0].Points[i].Elevation = 100 + float64(i-10)*8
+		default:
+			track.Segments[0].Points[i].Elevation = 180 + float64(i-20)*4
+		}
+		track.Segments[0].Points[i].HasElevation = true
+	}
+	doc := Document{Tracks: []Track{track}}
+
+	start, grade := doc.SteepestKm()
+	if math.Abs(start-1000) > 0.01 {
+		t.Errorf("got %f start; expected 1000", start)
+	}
+	if math.Abs(grade-8) > 0.01 {
+		t.Errorf("got %f grade; expected 8", grade)
+	}
+
+	short := Document{Tracks: []Track{{Segments: []Segment{{Points: track.Segments[0].Points[10:15]}}}}}
+	if start, grade := short.SteepestKm(); start != 0 || math.Abs(grade-8) > 0.01 {
+		t.Errorf("got %f start and %f grade; expected 0 and 8", start, grade)
+	}
+}
+
+func TestSplits(t *testing.T) {
+	t0 := time.Date(2015, 12, 13, 18, 0, 0, 0, time.UTC)
+	track := GenerateTrack(GenerateOptions{Points: 26, Spacing: 100, Start: Point{Latitude: 49.4, Longitude: 11.1, Time: t0}, Step: 30 * time.Second})
+	doc := Document{Tracks: []Track{track}}
+
+	splits := doc.Splits(1000)
+	if l := len(splits); l != 3 {
+		t.Fatalf("got %d split(s); expected 3", l)
+	}
+	for i, split := range splits[:2] {
+		if split.Index != i {
+			t.Errorf("got %d index; expected %d", split.Index, i)
+		}
+		if math.Abs(split.Distance-1000) > 0.01 {
+			t.Errorf("split %d: got %f distance; expected 1000", i, split.Distance)
+		}
+		if d := split.Duration - 5*time.Minute; d < -time.Second || d > time.Second {
+			t.Errorf("split %d: got %s duration; expected 5m", i, split.Duration)
+		}
+	}
+	last := splits[2]
+	if math.Abs(last.Distance-500) > 0.01 {
+		t.Errorf("got %f distance for the last split; expected 500", last.Distance)
+	}
+	if d := last.Pace - 5*time.Minute; d < -time.Second || d > time.Second {
+		t.Errorf("got %s pace for the last split; expected 5m", last.Pace)
+	}
+
+	if splits := (Document{}).Splits(1000); splits != nil {
+		t.Errorf("got %d split(s) for an empty document; expected none", len(splits))
+	}
+}
+
+func TestElevationAt(t *testing.T) {
+	track := GenerateTrack(GenerateOptions{Points: 3, Spacing: 100, Start: Point{Latitude: 49.4, Longitude: 11.1}})
+	for i, ele := range []float64{100, 120, 110} {
+		track.Segments[0].Points[i].Elevation = ele
+		track.Segments[0].Points[i].HasElevation = true
+	}
+	doc := Document{Tracks: []Track{track}}
+
+	testCases := []struct {
+		distance float64
+		ele      float64
+		ok       bool
+	}{
+		{0, 100, true},
+		{50, 110, true},
+		{150, 115, true},
+		{-1, 0, false},
+		{201, 0, false},
+	}
+
+	for _, testCase := range testCases {
+		ele, ok := doc.ElevationAt(testCase.distance)
+		if ok != testCase.ok || math.Abs(ele-testCase.ele) > 0.01 {
+			t.Errorf("got %f, %t at %f; expected %f, %t", ele, ok, testCase.distance, testCase.ele, testCase.ok)
+		}
+	}
+}
+
+func TestElevationAtMissingElevation(t *testing.T) {
+	track := GenerateTrack(GenerateOptions{Points: 4, Spacing: 100, Start: Point{Latitude: 49.4, Longitude: 11.1}})
+	points := track.Segments[0].Points
+	for _, i := range []int{1, 3} {
+		points[i].Elevation = 100 + float64(i)*10
+		points[i].HasElevation = true
+	}
+	doc := Document{Tracks: []Track{track}}
+
+	if _, ok := doc.ElevationAt(50); ok {
+		t.Error("expected no elevation before the first point with one")
+	}
+	if ele, ok := doc.ElevationAt(200); !ok || math.Abs(ele-120) > 0.01 {
+		t.Errorf("got %f, %t across a point without elevation; expected 120, true", ele, ok)
+	}
+	if start, grade := doc.SteepestKm(); math.Abs(start-100) > 0.01 || math.Abs(grade-10) > 0.01 {
+		t.Errorf("got %f%% from %f; expected 10%% from 100", grade, start)
+	}
+
+	doc = decodeFile(t, "test/empty_ele.gpx")
+	if _, ok := doc.ElevationAt(0); ok {
+		t.Error("expected no elevation at a point with an empty <ele>")
+	}
+}
+
+func TestSegmentMovingMask(t *testing.T) {
+	t0 := time.Date(2015, 12, 13, 18, 0, 0, 0, time.UTC)
+	track := GenerateTrack(GenerateOptions{Points: 6, Spacing: 10, Start: Point{Latitude: 49.4, Longitude: 11.1, Time: t0}, Step: 5 * time.Second})
+	points := track.Segments[0].Points
+	points[3].Latitude, points[3].Longitude = points[2].Latitude, points[2].Longitude
+	points[4].Time = points[3].Time
+	points[4].Latitude, points[4].Longitude = points[2].Latitude, points[2].Longitude
+	points[5].Time = points[5].Time.Add(time.Minute)
+
+	mask := track.Segments[0].MovingMask(1)
+	expected := []bool{true, true, true, false, false, false}
+	for i := range expected {
+		if mask[i] != expected[i] {
+			t.Errorf("got %v mask; expected %v", mask, expected)
+			break
+		}
+	}
+}
+
+func TestTimeOfDayHistogram(t *testing.T) {
+	t0 := time.Date(2015, 12, 13, 18, 50, 0, 0, time.UTC)
+	track := GenerateTrack(GenerateOptions{Points: 5, Spacing: 600, Start: Point{Latitude: 49.4, Longitude: 11.1, Time: t0}, Step: 5 * time.Minute})
+	doc := Document{Tracks: []Track{track}}
+
+	histogram := doc.TimeOfDayHistogram(nil)
+	if histogram[18] != 10*time.Minute || histogram[19] != 10*time.Minute {
+		t.Errorf("got %s and %s at 18h and 19h; expected 10m each", histogram[18], histogram[19])
+	}
+
+	cet := time.FixedZone("CET", 3600)
+	histogram = doc.TimeOfDayHistogram(cet)
+	if histogram[19] != 10*time.Minute || histogram[20] != 10*time.Minute {
+		t.Errorf("got %s and %s at 19h and 20h; expected 10m each", histogram[19], histogram[20])
+	}
+
+	ist := time.FixedZone("IST", 5*3600+1800)
+	histogram = doc.TimeOfDayHistogram(ist)
+	if histogram[0] != 20*time.Minute || histogram[1] != 0 {
+		t.Errorf("got %s and %s at 0h and 1h; expected 20m and 0", histogram[0], histogram[1])
+	}
+
+	if histogram := (Document{}).TimeOfDayHistogram(nil); histogram != [24]time.Duration{} {
+		t.Errorf("got %v; expected an empty histogram", histogram)
+	}
+}
+
+func TestIsNegativeSplit(t *testing.T) {
+	t0 := time.Date(2015, 12, 13, 18, 0, 0, 0, time.UTC)
+	track := GenerateTrack(GenerateOptions{Points: 11, Spacing: 100, Start: Point{Latitude: 49.4, Longitude: 11.1, Time: t0}, Step: 30 * time.Second})
+	doc := Document{Tracks: []Track{track}}
+	points := track.Segments[0].Points
+	for i := 6; i < len(points); i++ {
+		points[i].Time = points[i].Time.Add(time.Duration(i-5) * 5 * time.Second)
+	}
+	if doc.IsNegativeSplit() {
+		t.Error("expected slower second half not to be a negative split")
+	}
+
+	for i := 6; i < len(points); i++ {
+		points[i].Time = points[i].Time.Add(-time.Duration(i-5) * 10 * time.Second)
+	}
+	if !doc.IsNegativeSplit() {
+		t.Error("expected faster second half to be a negative split")
+	}
+
+	if (Document{}).IsNegativeSplit() {
+		t.Error("expected empty document not to be a negative split")
+	}
+}
+
+func TestDistanceAboveGrade(t *testing.T) {
+	track := GenerateTrack(GenerateOptions{Points: 5, Spacing: 100, Start: Point{Latitude: 49.4, Longitude: 11.1}})
+	for i, ele := range []float64{100, 110, 112, 120, 100} {
+		track.Segments[0].Points[i].Elevation = ele
+		track.Segments[0].Points[i].HasElevation = true
+	}
+	doc := Document{Tracks: []Track{track}}
+
+	if dist := doc.DistanceAboveGrade(5); math.Abs(dist-200) > 0.01 {
+		t.Errorf("got %f distance above 5%%; expected 200", dist)
+	}
+	if dist := doc.DistanceAboveGrade(1); math.Abs(dist-300) > 0.01 {
+		t.Errorf("got %f distance above 1%%; expected 300", dist)
+	}
+
+	// A point without elevation between two points at 300m is no climb.
+	track = GenerateTrack(GenerateOptions{Points: 3, Spacing: 100, Start: Point{Latitude: 49.4, Longitude: 11.1}})
+	for _, i := range []int{0, 2} {
+		track.Segments[0].Points[i].Elevation = 300
+		track.Segments[0].Points[i].HasElevation = true
+	}
+	doc = Document{Tracks: []Track{track}}
+	if dist := doc.DistanceAboveGrade(1); dist != 0 {
+		t.Errorf("got %f distance above 1%% around a point without elevation; expected 0", dist)
+	}
+	if dist := doc.AscentDistance() + doc.DescentDistance(); dist != 0 {
+		t.Errorf("got %f ascent and descent distance around a point without elevation; expected 0", dist)
+	}
+}
+
+func TestNoiseLevel(t *testing.T) {
+	straight := Segment{Points: []Point{
+		{Latitude: 0, Longitude: 0},
+		{Latitude: 0, Longitude: 0.001},
+		{Latitude: 0, Longitude: 0.002},
+		{Latitude: 0, Longitude: 0.003},
+	}}
+	if n := straight.NoiseLevel(); n > 0.001 {
+		t.Errorf("got %v noise level; expected 0", n)
+	}
+
+	// The first and last interior points sit 0.0001° (about 11.1m) off the
+	// equator; the middle one lies on the line between its neighbours.
+	zigzag := Segment{Points: []Point{
+		{Latitude: 0, Longitude: 0},
+		{Latitude: 0.0001, Longitude: 0.001},
+		{Latitude: 0, Longitude: 0.002},
+		{Latitude: -0.0001, Longitude: 0.003},
+		{Latitude: 0, Longitude: 0.004},
+	}}
+	if n := zigzag.NoiseLevel(); math.Abs(n-7.41) > 0.05 {
+		t.Errorf("got %v noise level; expected 7.41", n)
+	}
+
+	if n := (Segment{Points: straight.Points[:2]}).NoiseLevel(); n != 0 {
+		t.Errorf("got %v noise level for two points; expected 0", n)
+	}
+}
+
+func TestPointAt(t *testing.T) {
+	t0 := time.Date(2015, 12, 13, 18, 0, 0, 0, time.UTC)
+	doc := FromPoints([]Point{
+		{Latitude: 49.4, Longitude: 11.1, Elevation: 300, HasElevation: true, Time: t0},
+		{Latitude: 49.4, Longitude: 11.1, Elevation: 300, HasElevation: true, Time: t0},
+		{Latitude: 49.402, Longitude: 11.104, Elevation: 310, HasElevation: true, Time: t0.Add(20 * time.Second)},
+	})
+
+	p, ok := doc.PointAt(t0.Add(10 * time.Second))
+	if !ok {
+		t.Fatal("expected a point halfway")
+	}
+	if math.Abs(p.Latitude-49.401) > 1e-9 || math.Abs(p.Longitude-11.102) > 1e-9 {
+		t.Errorf("got %v,%v position; expected 49.401,11.102", p.Latitude, p.Longitude)
+	}
+	if !p.HasElevation || math.Abs(p.Elevation-305) > 1e-9 {
+		t.Errorf("got %v elevation; expected 305", p.Elevation)
+	}
+
+	if _, ok := doc.PointAt(t0.Add(-time.Second)); ok {
+		t.Error("expected no point before the track")
+	}
+	if _, ok := FromPoints([]Point{{Latitude: 1}, {Latitude: 2}}).PointAt(t0); ok {
+		t.Error("expected no point for a track without timestamps")
+	}
+}
+
+func TestPointAtGap(t *testing.T) {
+	t0 := time.Date(2015, 12, 13, 18, 0, 0, 0, time.UTC)
+	doc := FromPoints([]Point{
+		{Latitude: 0, Longitude: 0, Time: t0},
+		{Latitude: 0, Longitude: 0.001, Time: t0.Add(10 * time.Second)},
+		{Latitude: 0, Longitude: 0.005, Time: t0.Add(20 * time.Minute)},
+	})
+
+	p, ok := doc.PointAt(t0.Add(5 * time.Second))
+	if !ok {
+		t.Fatal("expected a point before the gap")
+	}
+	if math.Abs(p.Longitude-0.0005) > 1e-9 {
+		t.Errorf("got %v longitude; expected 0.0005", p.Longitude)
+	}
+
+	if _, ok := doc.PointAt(t0.Add(10 * time.Minute)); ok {
+		t.Error("expected no point inside the gap")
+	}
+	if _, ok := doc.PointAtWithMaxGap(t0.Add(10*time.Minute), 0); !ok {
+		t.Error("expected a point inside the gap when the check is disabled")
+	}
+	if p, ok := doc.PointAt(t0.Add(20 * time.Minute)); !ok || p.Longitude != 0.005 {
+		t.Errorf("got %+v, %v at the last point; expected an exact match", p, ok)
+	}
+}
+
+func TestPointAtSegmentBoundary(t *testing.T) {
+	t0 := time.Date(2015, 12, 13, 18, 0, 0, 0, time.UTC)
+	doc := Document{Tracks: []Track{{Segments: []Segment{
+		{Points: []Point{{Latitude: 0, Longitude: 0, Time: t0}, {Latitude: 0, Longitude: 0.001, Time: t0.Add(10 * time.Second)}}},
+		{Points: []Point{{Latitude: 0, Longitude: 0.002, Time: t0.Add(20 * time.Second)}, {Latitude: 0, Longitude: 0.003, Time: t0.Add(30 * time.Second)}}},
+	}}}}
+
+	if _, ok := doc.PointAt(t0.Add(15 * time.Second)); ok {
+		t.Error("expected no point between segments")
+	}
+	if _, ok := doc.PointAt(t0.Add(25 * time.Second)); !ok {
+		t.Error("expected a point inside the second segment")
+	}
+}
+
+func TestMovingAverageCadence(t *testing.T) {
+	t0 := time.Date(2015, 12, 13, 18, 0, 0, 0, time.UTC)
+	point := func(sec int, lon float64, cad uint) Point {
+		return Point{
+			Longitude:  lon,
+			Time:       t0.Add(time.Duration(sec) * time.Second),
+			Extensions: extensionTokens(fmt.Sprintf(`<gpxtpx:TrackPointExtension xmlns:gpxtpx="%s"><gpxtpx:cad>%d</gpxtpx:cad></gpxtpx:TrackPointExtension>`, GarminTrackPointExtensionNS, cad)),
+		}
+	}
+	// Roughly 11m/s while riding, then stopped at a traffic light while the
+	// cadence sensor still reports a slow spin.
+	doc := FromPoints([]Point{
+		point(0, 0, 90),
+		point(10, 0.001, 90),
+		point(20, 0.002, 80),
+		point(30, 0.002, 10),
+		point(40, 0.002, 10),
+		point(50, 0.003, 0),
+	})
+
+	cadence, ok := doc.MovingAverageCadence(DefaultMovingSpeed)
+	if !ok {
+		t.Fatal("expected a moving average cadence")
+	}
+	if expected := uint(86); cadence != expected {
+		t.Errorf("got %d moving average cadence; expected %d", cadence, expected)
+	}
+
+	if _, ok := FromPoints([]Point{{Time: t0}, {Longitude: 0.001, Time: t0.Add(time.Second)}}).MovingAverageCadence(DefaultMovingSpeed); ok {
+		t.Error("expected no moving average cadence without cadence data")
+	}
+}
+
+func TestClimbRate(t *testing.T) {
+	// Two kilometers along the equator, climbing 30m and descending 10m.
+	doc := FromPoints([]Point{
+		{Longitude: 0, Elevation: 100, HasElevation: true},
+		{Longitude: 0.008993, Elevation: 130, HasElevation: true},
+		{Longitude: 0.017986, Elevation: 120, HasElevation: true},
+	})
+
+	if rate := doc.ClimbRate(); math.Abs(rate-15) > 0.01 {
+		t.Errorf("got %v climb rate; expected 15", rate)
+	}
+	if rate := (FromPoints([]Point{{Elevation: 100, HasElevation: true}, {Elevation: 200, HasElevation: true}})).ClimbRate(); rate != 0 {
+		t.Errorf("got %v climb rate without distance; expected 0", rate)
+	}
+}
+
+func TestIsRegularlySampled(t *testing.T) {
+	t0 := time.Date(2015, 12, 13, 18, 0, 0, 0, time.UTC)
+	segment := func(secs ...int) Segment {
+		var s Segment
+		for _, sec := range secs {
+			s.Points = append(s.Points, Point{Time: t0.Add(time.Duration(sec) * time.Second)})
+		}
+		return s
+	}
+
+	testCases := []struct {
+		seg      Segment
+		expected bool
+	}{
+		{segment(0, 1, 2, 3, 4), true},
+		{segment(0, 1, 2, 4, 5), true},
+		{segment(0, 1, 2, 30, 31), false},
+		{segment(0), false},
+	}
+	for i, tc := range testCases {
+		if regular := tc.seg.IsRegularlySampled(500 * time.Millisecond); regular != tc.expected {
+			t.Errorf("got %v for case %d; expected %v", regular, i, tc.expected)
+		}
+	}
+}
+
+func TestElevationGainLoss(t *testing.T) {
+	f, err := os.Open("test/test.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := NewDecoder(f).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name     string
+		value    float64
+		expected float64
+	}{
+		// 346.874 -> 355.754 and 341.746 -> 346.115
+		{"gain", doc.ElevationGain(), 13.2490234375},
+		// 355.754 -> 341.746
+		{"loss", doc.ElevationLoss(), 14.00787353515625},
+		// 346.874 -> 349.926 -> 355.594 and 341.746 -> 346.115
+		{"gain with threshold", doc.ElevationGainThreshold(3), 13.0888671875},
+		// 355.594 -> 341.746
+		{"loss with threshold", doc.ElevationLossThreshold(3), 13.84771728515625},
+	}
+	for _, tc := range testCases {
+		if math.Abs(tc.value-tc.expected) > 1e-9 {
+			t.Errorf("got %v %s; expected %v", tc.value, tc.name, tc.expected)
+		}
+	}
+}
+
+func TestElevationGainMissingElevation(t *testing.T) {
+	doc := FromPoints([]Point{
+		{Elevation: 100, HasElevation: true},
+		{},
+		{Elevation: 110, HasElevation: true},
+		{Elevation: 0, HasElevation: true},
+	})
+
+	if gain := doc.ElevationGain(); gain != 10 {
+		t.Errorf("got %v gain; expected 10", gain)
+	}
+	if loss := doc.ElevationLoss(); loss != 110 {
+		t.Errorf("got %v loss; expected 110", loss)
+	}
+}
+
+func TestMovingDistance(t *testing.T) {
+	t0 := time.Date(2015, 12, 13, 18, 0, 0, 0, time.UTC)
+	// Riding 111m every 10 seconds, then drifting 1.1m every 10 seconds
+	// while standing still.
+	doc := FromPoints([]Point{
+		{Longitude: 0, Time: t0},
+		{Longitude: 0.001, Time: t0.Add(10 * time.Second)},
+		{Longitude: 0.002, Time: t0.Add(20 * time.Second)},
+		{Longitude: 0.00201, Time: t0.Add(30 * time.Second)},
+		{Longitude: 0.00202, Time: t0.Add(40 * time.Second)},
+	})
+
+	moving := doc.MovingDistance(DefaultMovingSpeed)
+	if expected := haversine(0, 0, 0, 0.002); math.Abs(moving-expected) > 1e-6 {
+		t.Errorf("got %v moving distance; expected %v", moving, expected)
+	}
+	if total := doc.DistanceInMeters(); moving >= total {
+		t.Errorf("got %v moving distance; expected less than the total of %v", moving, total)
+	}
+}
+
+func TestMovingTime(t *testing.T) {
+	f, err := os.Open("test/pause.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := NewDecoder(f).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		opts     MovingOptions
+		expected time.Duration
+	}{
+		{MovingOptions{}, 10*time.Minute + 40*time.Second},
+		{MovingOptions{MinSpeed: DefaultMovingSpeed}, 40 * time.Second},
+		{MovingOptions{MaxGap: time.Minute}, 40 * time.Second},
+		{MovingOptions{MinSpeed: DefaultMovingSpeed, Trim: Trim{Points: 1}}, 20 * time.Second},
+	}
+	for _, tc := range testCases {
+		if moving := doc.MovingTime(tc.opts); moving != tc.expected {
+			t.Errorf("got %v moving time with %+v; expected %v", moving, tc.opts, tc.expected)
+		}
+	}
+
+	if moving, total := doc.MovingTime(MovingOptions{MinSpeed: DefaultMovingSpeed}), doc.Duration(); moving >= total {
+		t.Errorf("got %v moving time; expected less than the total of %v", moving, total)
+	}
+}
+
+func TestTurnCount(t *testing.T) {
+	// East for 4 edges, north for 4 edges and then east again.
+	var points []Point
+	lat, lon := 0.0, 0.0
+	for _, step := range []struct{ dlat, dlon float64 }{
+		{0, 0.001}, {0, 0.001}, {0, 0.001}, {0, 0.001},
+		{0.001, 0}, {0.001, 0}, {0.001, 0}, {0.001, 0},
+		{0, 0.001}, {0, 0.001}, {0, 0.001}, {0, 0.001},
+	} {
+		points = append(points, Point{Latitude: lat, Longitude: lon})
+		lat, lon = lat+step.dlat, lon+step.dlon
+	}
+	points = append(points, Point{Latitude: lat, Longitude: lon})
+
+	if turns := (Segment{Points: points}).TurnCount(45); turns != 2 {
+		t.Errorf("got %d turn(s); expected 2", turns)
+	}
+
+	// A straight line east with one jittery position.
+	jitter := Segment{Points: []Point{
+		{Longitude: 0},
+		{Longitude: 0.001},
+		{Longitude: 0.002},
+		{Latitude: 0.0003, Longitude: 0.003},
+		{Longitude: 0.004},
+		{Longitude: 0.005},
+		{Longitude: 0.006},
+	}}
+	if turns := jitter.TurnCount(30); turns != 0 {
+		t.Errorf("got %d turn(s) for jitter; expected 0", turns)
+	}
+}
@@ -0,0 +1,115 @@
+package gpx
+
+import (
+	"context"
+	"encoding/xml"
+)
+
+// Stream decodes the document's track points one at a time, calling
+// handler for each of them with the index of its track and segment, without
+// retaining the points or building a Document. This keeps memory usage
+// constant for arbitrarily large documents. Everything but the track points
+// is skipped. If handler returns an error, decoding stops and Stream returns
+// that error. Like Decode, Stream returns ErrTruncated in non-strict mode
+// when the input ends prematurely.
+func (d *Decoder) Stream(handler func(pt Point, trackIdx, segIdx int) error) error {
+	if _, err := d.begin(context.Background()); err != nil {
+		return err
+	}
+
+	err := d.streamGPX(handler)
+	if err != nil && !d.Strict && isTruncation(err) {
+		d.log(ErrTruncated.Error())
+		return ErrTruncated
+	}
+	return err
+}
+
+func (d *Decoder) streamGPX(handler func(pt Point, trackIdx, segIdx int) error) error {
+	var trackIdx int
+	for {
+		tok, err := d.ts.Token()
+		if err != nil {
+			return err
+		}
+		switch tok.(type) {
+		case xml.StartElement:
+			se := tok.(xml.StartElement)
+			switch se.Name.Local {
+			case "trk":
+				if err := d.streamTrack(trackIdx, handler); err != nil {
+					return err
+				}
+				trackIdx++
+			default:
+				if err := d.ts.skipTag(); err != nil {
+					return err
+				}
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+func (d *Decoder) streamTrack(trackIdx int, handler func(pt Point, trackIdx, segIdx int) error) error {
+	var segIdx int
+	for {
+		tok, err := d.ts.Token()
+		if err != nil {
+			return err
+		}
+		switch tok.(type) {
+		case xml.StartElement:
+			se := tok.(xml.StartElement)
+			switch se.Name.Local {
+			case "trkseg":
+				if err := d.streamSegment(trackIdx, segIdx, handler); err != nil {
+					return err
+				}
+				segIdx++
+			default:
+				if err := d.ts.skipTag(); err != nil {
+					return err
+				}
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+func (d *Decoder) streamSegment(trackIdx, segIdx int, handler func(pt Point, trackIdx, segIdx int) error) error {
+	for {
+		tok, err := d.ts.Token()
+		if err != nil {
+			return err
+		}
+		switch tok.(type) {
+		case xml.StartElement:
+			se := tok.(xml.StartElement)
+			switch se.Name.Local {
+			case "trkpt":
+				point, err := d.consumePoint(se)
+				if err != nil {
+					return err
+				}
+				if d.pointFilter != nil {
+					var keep bool
+					if point, keep = d.pointFilter(point); !keep {
+						continue
+					}
+				}
+				if err := handler(point, trackIdx, segIdx); err != nil {
+					return err
+				}
+			default:
+				if err := d.ts.skipTag(); err != nil {
+					return err
+				}
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
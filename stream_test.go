@@ -0,0 +1,61 @@
+package gpx
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestDecoderStream(t *testing.T) {
+	doc := decodeFile(t, "test/test.gpx")
+
+	f, err := os.Open("test/test.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var points []Point
+	err = NewDecoder(f).Stream(func(p Point, trackIdx, segIdx int) error {
+		if trackIdx != 0 || segIdx != 0 {
+			t.Errorf("got point in track %d segment %d; expected track 0 segment 0", trackIdx, segIdx)
+		}
+		points = append(points, p)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l := len(points); l != 9 {
+		t.Errorf("got %d point(s); expected 9", l)
+	}
+	if !reflect.DeepEqual(points, doc.AllPoints()) {
+		t.Error("expected the streamed points to match the decoded points")
+	}
+}
+
+func TestDecoderStreamStop(t *testing.T) {
+	f, err := os.Open("test/test.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	stop := errors.New("stop")
+	var n int
+	err = NewDecoder(f).Stream(func(p Point, trackIdx, segIdx int) error {
+		n++
+		if n == 3 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop {
+		t.Errorf("got %v error; expected %v", err, stop)
+	}
+	if n != 3 {
+		t.Errorf("handler called %d time(s); expected 3", n)
+	}
+}
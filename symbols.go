@@ -0,0 +1,71 @@
+package gpx
+
+import "strings"
+
+// Symbols maps lower case waypoint symbol names as written by Garmin,
+// OsmAnd and other applications to a canonical symbol name. Applications
+// may add their own entries.
+var Symbols = map[string]string{
+	// Flags and pins
+	"flag":              "flag",
+	"flag, blue":        "flag",
+	"flag, green":       "flag",
+	"flag, red":         "flag",
+	"special_flag":      "flag",
+	"pin":               "pin",
+	"pin, blue":         "pin",
+	"pin, green":        "pin",
+	"pin, red":          "pin",
+	"special_marker":    "pin",
+	"waypoint":          "pin",
+	"navaid, blue":      "pin",
+	"navaid, green":     "pin",
+	"navaid, red":       "pin",
+	"navaid, white":     "pin",
+	"navaid, amber":     "pin",
+	"navaid, black":     "pin",
+	"navaid, orange":    "pin",
+	"navaid, violet":    "pin",
+	"navaid, red/grn":   "pin",
+	"navaid, red/wht":   "pin",
+	"navaid, white/grn": "pin",
+	"navaid, white/red": "pin",
+
+	// Amenities
+	"campground":             "campground",
+	"tourism_camp_site":      "campground",
+	"parking area":           "parking",
+	"amenity_parking":        "parking",
+	"restaurant":             "restaurant",
+	"amenity_restaurant":     "restaurant",
+	"drinking water":         "drinking-water",
+	"amenity_drinking_water": "drinking-water",
+	"restroom":               "toilets",
+	"amenity_toilets":        "toilets",
+	"gas station":            "fuel",
+	"amenity_fuel":           "fuel",
+	"lodging":                "lodging",
+	"tourism_hotel":          "lodging",
+	"information":            "information",
+	"tourism_information":    "information",
+
+	// Outdoors
+	"summit":            "summit",
+	"natural_peak":      "summit",
+	"scenic area":       "viewpoint",
+	"tourism_viewpoint": "viewpoint",
+	"trail head":        "trailhead",
+	"highway_trailhead": "trailhead",
+	"danger area":       "danger",
+	"hazard_danger":     "danger",
+}
+
+// NormalizeSymbol returns the canonical name of the waypoint symbol raw as
+// listed in Symbols. The lookup ignores case and surrounding white space.
+// Unknown symbols are returned unchanged.
+func NormalizeSymbol(raw string) string {
+	if sym, ok := Symbols[strings.ToLower(strings.TrimSpace(raw))]; ok {
+		return sym
+	}
+	return raw
+}
@@ -0,0 +1,22 @@
+package gpx
+
+import "testing"
+
+func TestNormalizeSymbol(t *testing.T) {
+	testCases := []struct {
+		raw string
+		sym string
+	}{
+		{"Flag, Blue", "flag"},
+		{"special_flag", "flag"},
+		{" Parking Area ", "parking"},
+		{"amenity_parking", "parking"},
+		{"Unknown Thing", "Unknown Thing"},
+	}
+
+	for _, testCase := range testCases {
+		if sym := NormalizeSymbol(testCase.raw); sym != testCase.sym {
+			t.Errorf("got %q for %q; expected %q", sym, testCase.raw, testCase.sym)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package gpx
 
 import (
+	"context"
 	"encoding/xml"
 	"errors"
 	"io"
@@ -27,6 +28,28 @@ func (t *sliceTokener) Token() (xml.Token, error) {
 	return tok, nil
 }
 
+// contextCheckInterval is the number of tokens a contextTokener provides
+// between checks of its context.
+const contextCheckInterval = 256
+
+// A contextTokener provides tokens from a tokener until its context is
+// done.
+type contextTokener struct {
+	tokener
+	ctx context.Context
+	n   int
+}
+
+func (t *contextTokener) Token() (xml.Token, error) {
+	if t.n%contextCheckInterval == 0 {
+		if err := t.ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+	t.n++
+	return t.tokener.Token()
+}
+
 // A tokenStream operates on a stream of tokens from a tokener.
 type tokenStream struct {
 	tokener
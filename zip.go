@@ -0,0 +1,47 @@
+package gpx
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// ZipDocument is a document decoded from an entry of a ZIP archive.
+type ZipDocument struct {
+	Name string // Name of the archive entry
+	Document
+}
+
+// DecodeZip decodes every *.gpx entry of the ZIP archive read from r, which
+// is size bytes long. The documents are returned in archive order along with
+// their entry names. Other entries are ignored.
+func DecodeZip(r io.ReaderAt, size int64, opts ...Option) ([]ZipDocument, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []ZipDocument
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || !strings.EqualFold(path.Ext(f.Name), ".gpx") {
+			continue
+		}
+		doc, err := decodeZipFile(f, opts)
+		if err != nil {
+			return docs, fmt.Errorf("gpx: %s: %s", f.Name, err)
+		}
+		docs = append(docs, ZipDocument{Name: f.Name, Document: doc})
+	}
+	return docs, nil
+}
+
+func decodeZipFile(f *zip.File, opts []Option) (Document, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return Document{}, err
+	}
+	defer rc.Close()
+	return NewDecoder(rc, opts...).Decode()
+}
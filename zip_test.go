@@ -0,0 +1,46 @@
+package gpx
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestDecodeZip(t *testing.T) {
+	data, err := ioutil.ReadFile("test/test.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range []string{"run.gpx", "readme.txt", "activities/RUN.GPX"} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	docs, err := DecodeZip(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := len(docs); l != 2 {
+		t.Fatalf("got %d document(s); expected 2", l)
+	}
+	for i, expected := range []string{"run.gpx", "activities/RUN.GPX"} {
+		if docs[i].Name != expected {
+			t.Errorf("got %q name; expected %q", docs[i].Name, expected)
+		}
+		if l := len(docs[i].AllPoints()); l != 9 {
+			t.Errorf("%s: got %d point(s); expected 9", docs[i].Name, l)
+		}
+	}
+}